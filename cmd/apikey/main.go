@@ -0,0 +1,54 @@
+// Command apikey creates an API key directly in the database, e.g. the first
+// admin key of a new deployment. Later keys can be managed through
+// /api/admin/api-keys.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"scheduler-service/internal/app"
+)
+
+func main() {
+	name := flag.String("name", "", "key name")
+	owner := flag.String("owner", "", "user ID the key acts as")
+	tenant := flag.String("tenant", "", "organization ID the key acts in")
+	scopes := flag.String("scopes", app.ScopeAdmin, "comma-separated scopes")
+	ttl := flag.Duration("ttl", 0, "lifetime of the key; 0 never expires")
+	flag.Parse()
+
+	if *name == "" || *owner == "" || *tenant == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL required")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		log.Fatalf("failed to connect to db: %v", err)
+	}
+	defer pool.Close()
+
+	k := &app.APIKey{Name: *name, OwnerID: *owner, TenantID: *tenant, Scopes: strings.Split(*scopes, ","), CreatedBy: "cli"}
+	if *ttl > 0 {
+		expires := time.Now().Add(*ttl)
+		k.ExpiresAt = &expires
+	}
+	secret, err := (&app.App{DB: pool}).CreateAPIKey(ctx, k)
+	if err != nil {
+		log.Fatalf("failed to create api key: %v", err)
+	}
+	fmt.Printf("id:  %s\nkey: %s\n", k.ID, secret)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"log"
 	"os"
+	"time"
+	_ "time/tzdata" // embed the tz database so rule timezones validate on minimal images
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -20,41 +22,118 @@ func main() {
 		log.Fatal("DATABASE_URL required")
 	}
 
-	pool, err := pgxpool.New(ctx, dbURL)
+	// Work no tenant scopes (jobs, webhooks, OAuth callbacks) needs a role
+	// with BYPASSRLS; requests run as DATABASE_URL's, under row-level security
+	systemURL := os.Getenv("SYSTEM_DATABASE_URL")
+	if systemURL == "" {
+		log.Fatal("SYSTEM_DATABASE_URL required")
+	}
+
+	cfg, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		log.Fatalf("invalid DATABASE_URL: %v", err)
+	}
+	app.ScopeToTenant(cfg)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		log.Fatalf("failed to connect to db: %v", err)
 	}
 	defer pool.Close()
 
-	appInstance := &app.App{DB: pool}
+	system, err := pgxpool.New(ctx, systemURL)
+	if err != nil {
+		log.Fatalf("failed to connect to db: %v", err)
+	}
+	defer system.Close()
+
+	appInstance := &app.App{DB: pool, System: system}
+
+	// Renew calendar watch channels before Google expires them
+	go appInstance.RunCalendarChannelRenewal(ctx, time.Hour)
+	go appInstance.RunICSFeedRefresh(ctx, time.Minute)
 
 	router := gin.Default()
 	
 	// OAuth2 callback (must be before auth middleware)
 	router.GET("/oauth2callback", appInstance.GoogleOAuth2CallbackHandler)
+	router.GET("/oauth2callback/microsoft", appInstance.MicrosoftOAuth2CallbackHandler)
+	// opened by the browser to bind it to a pending authorization
+	router.GET("/oauth2callback/start", appInstance.OAuthStartHandler)
+	// Calendar push notifications; verified against the registered channel token
+	router.POST("/webhooks/google/calendar", appInstance.GoogleCalendarWebhookHandler)
 	
-	router.Use(app.AuthMiddlewareFromEnv())
+	router.Use(appInstance.AuthMiddlewareFromEnv())
 
 	api := router.Group("/api")
 	{
-		users := api.Group("/users")
+		// callers create their own profile before joining an organization
+		api.POST("/users", app.RequireScope(app.ScopeUsersWrite), appInstance.CreateUserHandler)
+
+		// host-owned resources; bookers may also read slots and book
+		users := api.Group("/users", app.RequireTenant(), appInstance.RequireUserAccess())
 		{
-			users.POST("/:id/availability", appInstance.SetAvailabilityHandler)
-			users.PUT("/:id/availability/:rule_id", appInstance.UpdateAvailabilityHandler)
-			users.GET("/:id/availability", appInstance.ListAvailabilityHandler)
-			users.GET("/:id/slots", appInstance.GetSlotsHandler)
-			users.POST("/:id/bookings", appInstance.CreateBookingHandler)
-			users.GET("/:id/bookings", appInstance.ListBookingsHandler)
+			users.GET("/:id", app.RequireScope(app.ScopeUsersRead), appInstance.GetUserHandler)
+			users.PUT("/:id", app.RequireScope(app.ScopeUsersWrite), appInstance.UpdateUserHandler)
+			users.DELETE("/:id", app.RequireScope(app.ScopeUsersWrite), appInstance.DeleteUserHandler)
+			users.POST("/:id/availability", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.SetAvailabilityHandler)
+			users.PUT("/:id/availability/:rule_id", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.UpdateAvailabilityHandler)
+			users.GET("/:id/availability", app.RequireScope(app.ScopeAvailabilityRead), appInstance.ListAvailabilityHandler)
+			users.POST("/:id/availability/overrides", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.CreateAvailabilityOverridesHandler)
+			users.GET("/:id/availability/overrides", app.RequireScope(app.ScopeAvailabilityRead), appInstance.ListAvailabilityOverridesHandler)
+			users.PUT("/:id/availability/overrides/:override_id", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.UpdateAvailabilityOverrideHandler)
+			users.DELETE("/:id/availability/overrides/:override_id", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.DeleteAvailabilityOverrideHandler)
+			users.GET("/:id/settings", app.RequireScope(app.ScopeAvailabilityRead), appInstance.GetUserSettingsHandler)
+			users.PUT("/:id/settings", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.UpdateUserSettingsHandler)
+			users.POST("/:id/event-types", app.RequireScope(app.ScopeEventTypesWrite), appInstance.CreateEventTypeHandler)
+			users.GET("/:id/event-types", app.RequireScope(app.ScopeEventTypesRead), appInstance.ListEventTypesHandler)
+			users.GET("/:id/event-types/:event_type_id", app.RequireScope(app.ScopeEventTypesRead), appInstance.GetEventTypeHandler)
+			users.PUT("/:id/event-types/:event_type_id", app.RequireScope(app.ScopeEventTypesWrite), appInstance.UpdateEventTypeHandler)
+			users.DELETE("/:id/event-types/:event_type_id", app.RequireScope(app.ScopeEventTypesWrite), appInstance.DeleteEventTypeHandler)
+			users.GET("/:id/bookings", app.RequireScope(app.ScopeBookingsRead), appInstance.ListBookingsHandler)
+			users.POST("/:id/ics-feeds", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.CreateICSFeedHandler)
+			users.GET("/:id/ics-feeds", app.RequireScope(app.ScopeAvailabilityRead), appInstance.ListICSFeedsHandler)
+			users.DELETE("/:id/ics-feeds/:feed_id", app.RequireScope(app.ScopeAvailabilityWrite), appInstance.DeleteICSFeedHandler)
+		}
+		booking := api.Group("/users", app.RequireTenant(), appInstance.RequireUserAccess(app.RoleBooker))
+		{
+			booking.GET("/:id/slots", app.RequireScope(app.ScopeSlotsRead), appInstance.GetSlotsHandler)
+			booking.POST("/:id/bookings", app.RequireScope(app.ScopeBookingsWrite), appInstance.CreateBookingHandler)
+		}
+		bookings := api.Group("/bookings", app.RequireTenant())
+		{
+			bookings.GET("/:id", app.RequireScope(app.ScopeBookingsRead), appInstance.GetBookingHandler)
+			bookings.POST("/:id/reschedule", app.RequireScope(app.ScopeBookingsWrite), appInstance.RescheduleBookingHandler)
+			bookings.DELETE("/:id", app.RequireScope(app.ScopeBookingsWrite), appInstance.CancelBookingHandler)
 		}
-		api.DELETE("/bookings/:id", appInstance.CancelBookingHandler)
 		
 		// Google Calendar integration routes
-		calendar := api.Group("/calendar")
+		calendar := api.Group("/calendar", app.RequireTenant(), appInstance.RequireUserAccess())
+		{
+			calendar.GET("/auth", app.RequireScope(app.ScopeCalendarWrite), appInstance.GoogleAuthHandler)
+			calendar.GET("/events", app.RequireScope(app.ScopeCalendarRead), appInstance.GetGoogleCalendarEvents)
+			calendar.GET("/calendars", app.RequireScope(app.ScopeCalendarRead), appInstance.GetCalendarList)
+			calendar.PUT("/calendars", app.RequireScope(app.ScopeCalendarWrite), appInstance.UpdateCalendarSelectionHandler)
+			calendar.POST("/refresh-token", app.RequireScope(app.ScopeCalendarWrite), appInstance.RefreshGoogleToken)
+			calendar.POST("/watch", app.RequireScope(app.ScopeCalendarWrite), appInstance.WatchGoogleCalendarHandler)
+			calendar.DELETE("/watch", app.RequireScope(app.ScopeCalendarWrite), appInstance.UnwatchGoogleCalendarHandler)
+			calendar.POST("/caldav/connect", app.RequireScope(app.ScopeCalendarWrite), appInstance.ConnectCalDAVHandler)
+			calendar.GET("/microsoft/auth", app.RequireScope(app.ScopeCalendarWrite), appInstance.MicrosoftAuthHandler)
+		}
+
+		orgs := api.Group("/organizations")
+		{
+			orgs.POST("", app.RequireScope(app.ScopeAdmin), appInstance.CreateOrganizationHandler)
+			orgs.GET("", appInstance.ListOrganizationsHandler)
+			orgs.GET("/:org_id/members", appInstance.ListMembershipsHandler)
+			orgs.PUT("/:org_id/members/:user_id", app.RequireScope(app.ScopeAdmin), appInstance.UpsertMembershipHandler)
+			orgs.DELETE("/:org_id/members/:user_id", app.RequireScope(app.ScopeAdmin), appInstance.DeleteMembershipHandler)
+		}
+
+		admin := api.Group("/admin", app.RequireTenant(), app.RequireRole(app.RoleAdmin), app.RequireScope(app.ScopeAdmin))
 		{
-			calendar.GET("/auth", appInstance.GoogleAuthHandler)
-			calendar.GET("/events", appInstance.GetGoogleCalendarEvents)
-			calendar.GET("/calendars", appInstance.GetGoogleCalendarList)
-			calendar.POST("/refresh-token", appInstance.RefreshGoogleToken)
+			admin.POST("/api-keys", appInstance.CreateAPIKeyHandler)
+			admin.GET("/api-keys", appInstance.ListAPIKeysHandler)
+			admin.DELETE("/api-keys/:key_id", appInstance.RevokeAPIKeyHandler)
 		}
 	}
 
@@ -0,0 +1,292 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// apiKeyPrefix marks bearer tokens that are API keys rather than JWTs.
+const apiKeyPrefix = "sk_"
+
+// API key scopes. A key may only call routes requiring one of its scopes;
+// ScopeAdmin grants every scope and makes the key act as an admin.
+const (
+	ScopeAvailabilityRead  = "availability:read"
+	ScopeAvailabilityWrite = "availability:write"
+	ScopeEventTypesRead    = "event_types:read"
+	ScopeEventTypesWrite   = "event_types:write"
+	ScopeSlotsRead         = "slots:read"
+	ScopeBookingsRead      = "bookings:read"
+	ScopeBookingsWrite     = "bookings:write"
+	ScopeCalendarRead      = "calendar:read"
+	ScopeCalendarWrite     = "calendar:write"
+	ScopeUsersRead         = "users:read"
+	ScopeUsersWrite        = "users:write"
+	ScopeAdmin             = "admin"
+)
+
+var knownScopes = map[string]bool{
+	ScopeAvailabilityRead:  true,
+	ScopeAvailabilityWrite: true,
+	ScopeEventTypesRead:    true,
+	ScopeEventTypesWrite:   true,
+	ScopeSlotsRead:         true,
+	ScopeBookingsRead:      true,
+	ScopeBookingsWrite:     true,
+	ScopeCalendarRead:      true,
+	ScopeCalendarWrite:     true,
+	ScopeUsersRead:         true,
+	ScopeUsersWrite:        true,
+	ScopeAdmin:             true,
+}
+
+// errAPIKeyNotFound is returned for an unknown or already revoked key.
+var errAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey is a stored key. The secret is shown once on creation; afterwards only
+// its prefix identifies it.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"owner_id"`  // the user the key acts as
+	TenantID   string     `json:"tenant_id"` // the organization it acts in
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitempty"`
+}
+
+// validateScopes rejects empty or unknown scopes.
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope required")
+	}
+	for _, s := range scopes {
+		if !knownScopes[s] {
+			return fmt.Errorf("unknown scope: %s", s)
+		}
+	}
+	return nil
+}
+
+func hashAPIKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// CreateAPIKey generates a key for k, stores its hash and returns the secret.
+func (a *App) CreateAPIKey(ctx context.Context, k *APIKey) (string, error) {
+	if err := validateScopes(k.Scopes); err != nil {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	k.Prefix = secret[:len(apiKeyPrefix)+8]
+
+	q := `INSERT INTO api_keys (id, name, owner_id, tenant_id, prefix, key_hash, scopes, expires_at, created_by, created_at)
+          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, now())
+          RETURNING id, created_at`
+	err := a.db(ctx).QueryRow(ctx, q, k.Name, k.OwnerID, k.TenantID, k.Prefix, hashAPIKey(secret), k.Scopes,
+		k.ExpiresAt, nullIfEmpty(k.CreatedBy)).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// ListAPIKeys returns tenantID's keys, newest first, including revoked ones.
+func (a *App) ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	q := `SELECT id, name, owner_id, tenant_id, prefix, scopes, expires_at, last_used_at, revoked_at,
+	             COALESCE(created_by,''), created_at
+	      FROM api_keys WHERE tenant_id=$1 ORDER BY created_at DESC`
+	rows, err := a.db(ctx).Query(ctx, q, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []APIKey
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.OwnerID, &k.TenantID, &k.Prefix, &k.Scopes, &k.ExpiresAt,
+			&k.LastUsedAt, &k.RevokedAt, &k.CreatedBy, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+// RevokeAPIKey disables one of tenantID's keys immediately.
+func (a *App) RevokeAPIKey(ctx context.Context, tenantID, id string) error {
+	tag, err := a.db(ctx).Exec(ctx, `UPDATE api_keys SET revoked_at=now()
+	                            WHERE id=$1 AND revoked_at IS NULL AND tenant_id=$2`, id, tenantID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errAPIKeyNotFound
+	}
+	return nil
+}
+
+// authenticateAPIKey resolves a presented key to a principal. Lookup is by
+// hash, so the secret is never compared directly. last_used_at is written at
+// most once a minute per key.
+func (a *App) authenticateAPIKey(ctx context.Context, secret string) (*Principal, error) {
+	var (
+		id, owner, tenant string
+		scopes            []string
+	)
+	q := `SELECT id, owner_id, tenant_id, scopes FROM api_keys
+	      WHERE key_hash=$1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())`
+	err := a.db(ctx).QueryRow(ctx, q, hashAPIKey(secret)).Scan(&id, &owner, &tenant, &scopes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.db(ctx).Exec(ctx, `UPDATE api_keys SET last_used_at=now()
+	                             WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`, id); err != nil {
+		return nil, err
+	}
+
+	p := &Principal{Subject: owner, Tenant: tenant, APIKeyID: id, Scopes: scopes}
+	for _, s := range scopes {
+		if s == ScopeAdmin {
+			p.Roles = append(p.Roles, RoleAdmin)
+		}
+	}
+	return p, nil
+}
+
+// RequireScope rejects API keys holding none of scopes. Other principals are
+// not scope-limited; ownership checks still apply to them.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := CurrentPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		for _, s := range scopes {
+			if p.HasScope(s) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks scope: " + strings.Join(scopes, " or ")})
+	}
+}
+
+// RequireRole rejects callers without role.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p, ok := CurrentPrincipal(c); !ok || !p.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+type createAPIKeyReq struct {
+	Name      string     `json:"name" binding:"required"`
+	OwnerID   string     `json:"owner_id" binding:"required"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// POST /admin/api-keys
+// Keys are created in the caller's organization, for one of its members. The
+// response is the only time the key itself is returned.
+func (a *App) CreateAPIKeyHandler(c *gin.Context) {
+	var req createAPIKeyReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	p, ok := CurrentPrincipal(c)
+	if !ok || p.Tenant == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := a.membershipRole(ctx, p.Tenant, req.OwnerID); errors.Is(err, errNotMember) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "owner is not a member of the organization"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	k := &APIKey{Name: req.Name, OwnerID: req.OwnerID, TenantID: p.Tenant, Scopes: req.Scopes,
+		ExpiresAt: req.ExpiresAt, CreatedBy: p.Subject}
+	secret, err := a.CreateAPIKey(ctx, k)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": k, "key": secret})
+}
+
+// GET /admin/api-keys
+func (a *App) ListAPIKeysHandler(c *gin.Context) {
+	p, ok := CurrentPrincipal(c)
+	if !ok || p.Tenant == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	keys, err := a.ListAPIKeys(c.Request.Context(), p.Tenant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// DELETE /admin/api-keys/:key_id
+func (a *App) RevokeAPIKeyHandler(c *gin.Context) {
+	p, ok := CurrentPrincipal(c)
+	if !ok || p.Tenant == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	err := a.RevokeAPIKey(c.Request.Context(), p.Tenant, c.Param("key_id"))
+	if errors.Is(err, errAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -3,5 +3,18 @@ package app
 import "github.com/jackc/pgx/v5/pgxpool"
 
 type App struct {
+	// DB serves requests. Its role is subject to row-level security, and
+	// ScopeToTenant limits each connection to the tenant of the acquiring context.
 	DB *pgxpool.Pool
+	// System connects as a role with BYPASSRLS, for work no tenant scopes;
+	// see asSystem.
+	System *pgxpool.Pool
+	// Busy supplies external busy time for slot generation; nil uses the
+	// user's connected calendar providers.
+	Busy BusySource
+	// Writer mirrors bookings onto the host's calendar; nil uses the host's
+	// target calendar where its provider's write-back is enabled.
+	Writer CalendarWriter
+
+	webhookSyncs syncCoalescer
 }
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -10,11 +12,119 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Auth middleware supporting static tokens or JWT
-func AuthMiddlewareFromEnv() gin.HandlerFunc {
-	staticTokens := strings.Split(strings.TrimSpace(os.Getenv("STATIC_TOKENS")), ",")
+// Roles carried in a token's "roles" claim
+const (
+	RoleAdmin  = "admin"  // may act on any user in the organization
+	RoleBooker = "booker" // may view the organization's hosts' slots and book them
+)
+
+// principalKey is the gin context key holding the caller's *Principal.
+const principalKey = "principal"
+
+// Principal is the authenticated caller. Subject is the user ID the caller
+// acts as; Tenant is the organization the request acts in, from the token's
+// tenant_id claim or the caller's only membership.
+// APIKeyID and Scopes are set when the caller used an API key.
+type Principal struct {
+	Subject  string
+	Tenant   string
+	Roles    []string
+	APIKeyID string
+	Scopes   []string
+}
+
+// HasRole reports whether p holds role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether p may use routes requiring scope. Only API keys are
+// limited by scopes, and ScopeAdmin grants all of them.
+func (p *Principal) HasScope(scope string) bool {
+	if p.APIKeyID == "" {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentPrincipal returns the caller set by the auth middleware.
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok
+}
+
+// principalFromClaims reads sub, tenant_id and roles. roles may be a list or a
+// space-separated string.
+func principalFromClaims(claims jwt.MapClaims) (*Principal, bool) {
+	sub, err := claims.GetSubject()
+	if err != nil || sub == "" {
+		return nil, false
+	}
+	p := &Principal{Subject: sub}
+	p.Tenant, _ = claims["tenant_id"].(string)
+	switch roles := claims["roles"].(type) {
+	case []any:
+		for _, r := range roles {
+			if s, ok := r.(string); ok {
+				p.Roles = append(p.Roles, s)
+			}
+		}
+	case string:
+		p.Roles = strings.Fields(roles)
+	}
+	return p, true
+}
+
+// Auth middleware. Each way of authenticating is enabled by its own setting:
+//   - JWT_JWKS_URL, or JWT_JWKS_FILE for air-gapped setups: RS256/ES256 tokens
+//     verified against the published keys; these must carry exp
+//   - JWT_HMAC_SECRET: HMAC-signed tokens
+//
+// API keys (bearer tokens starting with apiKeyPrefix) are always accepted and
+// checked against api_keys. JWT_ISSUER and JWT_AUDIENCE, when set, are
+// required of every JWT, and exp and nbf are checked whenever present. JWTs
+// must carry a subject.
+func (a *App) AuthMiddlewareFromEnv() gin.HandlerFunc {
+	if strings.TrimSpace(os.Getenv("STATIC_TOKENS")) != "" {
+		log.Printf("auth: STATIC_TOKENS is no longer supported and is ignored; create API keys instead")
+	}
 	jwtSecret := strings.TrimSpace(os.Getenv("JWT_HMAC_SECRET"))
 
+	var keySet *jwksKeySet
+	if url, file := strings.TrimSpace(os.Getenv("JWT_JWKS_URL")), strings.TrimSpace(os.Getenv("JWT_JWKS_FILE")); url != "" || file != "" {
+		keySet = newJWKSKeySet(url, file) // the URL wins when both are set
+	}
+
+	var methods []string
+	if jwtSecret != "" {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if keySet != nil {
+		methods = append(methods, "RS256", "ES256")
+	}
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithLeeway(5 * time.Second)}
+	if iss := strings.TrimSpace(os.Getenv("JWT_ISSUER")); iss != "" {
+		opts = append(opts, jwt.WithIssuer(iss))
+	}
+	if aud := strings.TrimSpace(os.Getenv("JWT_AUDIENCE")); aud != "" {
+		opts = append(opts, jwt.WithAudience(aud))
+	}
+	parser := jwt.NewParser(opts...)
+
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
 		if auth == "" {
@@ -28,24 +138,51 @@ func AuthMiddlewareFromEnv() gin.HandlerFunc {
 		}
 		tokenStr := parts[1]
 
-		// JWT path
-		if jwtSecret != "" {
-			_, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrTokenMalformed
-				}
-				return []byte(jwtSecret), nil
-			}, jwt.WithLeeway(5*time.Second))
-			if err == nil {
-				c.Next()
+		// API keys
+		if strings.HasPrefix(tokenStr, apiKeyPrefix) {
+			p, err := a.authenticateAPIKey(c.Request.Context(), tokenStr)
+			if errors.Is(err, errAPIKeyNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 				return
 			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check api key"})
+				return
+			}
+			if a.setPrincipal(c, p) {
+				c.Next()
+			}
+			return
 		}
 
-		// static tokens
-		for _, t := range staticTokens {
-			if tokenStr == strings.TrimSpace(t) {
-				c.Next()
+		// JWT path
+		if len(methods) > 0 {
+			claims := jwt.MapClaims{}
+			token, err := parser.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+				switch token.Method.(type) {
+				case *jwt.SigningMethodHMAC:
+					return []byte(jwtSecret), nil
+				case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+					kid, _ := token.Header["kid"].(string)
+					return keySet.key(c.Request.Context(), kid)
+				}
+				return nil, jwt.ErrTokenUnverifiable
+			})
+			if err == nil {
+				if _, hmac := token.Method.(*jwt.SigningMethodHMAC); !hmac {
+					if exp, _ := claims.GetExpirationTime(); exp == nil {
+						c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has no expiry"})
+						return
+					}
+				}
+				p, ok := principalFromClaims(claims)
+				if !ok {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has no subject"})
+					return
+				}
+				if a.setPrincipal(c, p) {
+					c.Next()
+				}
 				return
 			}
 		}
@@ -53,3 +190,78 @@ func AuthMiddlewareFromEnv() gin.HandlerFunc {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 	}
 }
+
+// canActFor reports whether the caller may act on userID's resources: as that
+// user, as an admin, or through one of roles. Either way the caller needs a
+// tenant, and userID must belong to it; users outside it are reported as
+// errUserNotFound, so other organizations' users can't be probed.
+func (a *App) canActFor(c *gin.Context, userID string, roles ...string) (bool, error) {
+	p, ok := CurrentPrincipal(c)
+	if !ok || p.Tenant == "" {
+		return false, nil
+	}
+	allowed := p.Subject == userID || p.HasRole(RoleAdmin)
+	for _, r := range roles {
+		allowed = allowed || p.HasRole(r)
+	}
+	if !allowed || p.Subject == userID {
+		return allowed, nil
+	}
+	_, err := a.membershipRole(c.Request.Context(), p.Tenant, userID)
+	if errors.Is(err, errNotMember) {
+		return false, errUserNotFound
+	}
+	return err == nil, err
+}
+
+// authorizeUser responds 403 unless the caller may act on userID's resources,
+// or 404 when userID is not a user of the caller's organization.
+func (a *App) authorizeUser(c *gin.Context, userID string, roles ...string) bool {
+	ok, err := a.canActFor(c, userID, roles...)
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return false
+	}
+	return true
+}
+
+// RequireUserAccess guards routes addressing a user by the :id path parameter,
+// or by the user_id query parameter where there is none. Requests naming no
+// user are left for the handler to reject.
+func (a *App) RequireUserAccess(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Param("id")
+		if userID == "" {
+			userID = c.Query("user_id")
+		}
+		if userID != "" && !a.authorizeUser(c, userID, roles...) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// setPrincipal resolves p's tenant and attaches both to the request.
+func (a *App) setPrincipal(c *gin.Context, p *Principal) bool {
+	err := a.resolveTenant(c.Request.Context(), p)
+	if errors.Is(err, errNotMember) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not a member of the token's organization"})
+		return false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve organization"})
+		return false
+	}
+	c.Set(principalKey, p)
+	c.Request = c.Request.WithContext(withTenant(c.Request.Context(), p.Tenant))
+	return true
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errCalendarUnavailable wraps failures of an external busy-time lookup.
+var errCalendarUnavailable = errors.New("calendar provider unavailable")
+
+// BusyPeriod is a span during which a host is busy in an external calendar.
+type BusyPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// BusySource reports when a host is busy outside the scheduler.
+// Users without a calendar connection have no busy time.
+type BusySource interface {
+	Busy(ctx context.Context, userID string, from, to time.Time) ([]BusyPeriod, error)
+}
+
+// busySource returns a.Busy, defaulting to the user's connected calendar providers.
+func (a *App) busySource() BusySource {
+	if a.Busy != nil {
+		return a.Busy
+	}
+	return &providerBusySource{app: a}
+}
+
+// externalBusy fetches busy intervals for userID from their calendars and ICS
+// feeds, wrapping provider failures in errCalendarUnavailable. Feeds are read
+// from the local cache, so their errors are internal ones.
+func (a *App) externalBusy(ctx context.Context, userID string, from, to time.Time) ([]interval, error) {
+	periods, err := a.busySource().Busy(ctx, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCalendarUnavailable, err)
+	}
+	out, err := a.icsBusy(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range periods {
+		out = append(out, interval{Start: p.Start, End: p.End})
+	}
+	return out, nil
+}
+
+// conflictsWithBusy reports whether a slot, padded by before/after, overlaps any busy interval.
+func conflictsWithBusy(slot interval, before, after time.Duration, busy []interval) bool {
+	padded := interval{Start: slot.Start.Add(-before), End: slot.End.Add(after)}
+	for _, b := range busy {
+		if padded.overlaps(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// respondSlotError reports a failure to compute availability, distinguishing
+// an unreachable calendar provider from internal errors. Provider errors may
+// carry upstream responses, so they are only logged.
+func respondSlotError(c *gin.Context, err error) {
+	if errors.Is(err, errCalendarUnavailable) {
+		log.Printf("calendar: busy time for %s: %v", c.Request.URL.Path, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": errCalendarUnavailable.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// providerBusySource merges free/busy from every calendar provider the user
+// has connected, limited to their conflict calendars. Push-synced calendars
+// are answered from the local cache.
+type providerBusySource struct {
+	app *App
+}
+
+func (s *providerBusySource) Busy(ctx context.Context, userID string, from, to time.Time) ([]BusyPeriod, error) {
+	providers, err := s.app.connectedProviders(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []BusyPeriod
+	for _, name := range providers {
+		sel, err := s.app.GetCalendarSelection(ctx, userID, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(sel.ConflictCalendarIDs) == 0 {
+			continue
+		}
+
+		// the cache keeps working while the provider is unreachable
+		cached, ok, err := s.app.cachedBusy(ctx, userID, name, sel.ConflictCalendarIDs, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, cached...)
+			continue
+		}
+
+		p, err := s.app.calendarProvider(ctx, userID, name)
+		if errors.Is(err, errNoCalendarConnection) || errors.Is(err, errProviderNotConfigured) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		periods, err := p.FreeBusy(ctx, sel.ConflictCalendarIDs, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		out = append(out, periods...)
+	}
+	return out, nil
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testApp returns an App on a fresh schema of TEST_DATABASE_URL with every
+// migration applied. Both pools share the test's role, which usually bypasses
+// row-level security. Tests needing a database are skipped without one.
+func testApp(t *testing.T) *App {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	raw := make([]byte, 6)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(raw)
+
+	admin, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close(ctx)
+	if _, err := admin.Exec(ctx, `CREATE SCHEMA `+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn, err := pgx.Connect(context.Background(), dsn)
+		if err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+			return
+		}
+		defer conn.Close(context.Background())
+		if _, err := conn.Exec(context.Background(), `DROP SCHEMA `+schema+` CASCADE`); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+	ScopeToTenant(cfg)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		sql, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			t.Fatalf("migration %s: %v", filepath.Base(f), err)
+		}
+	}
+	return &App{DB: pool, System: pool}
+}
+
+// fakeBusy is a BusySource with fixed busy periods, or a fixed error.
+type fakeBusy struct {
+	periods []BusyPeriod
+	err     error
+}
+
+func (f *fakeBusy) Busy(ctx context.Context, userID string, from, to time.Time) ([]BusyPeriod, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var out []BusyPeriod
+	for _, p := range f.periods {
+		if p.End.After(from) && p.Start.Before(to) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// seedHost creates an organization with one host who is available 09:00-12:00
+// UTC in hour-long slots on day's weekday, and returns a context acting in
+// that organization.
+func seedHost(t *testing.T, a *App, day time.Time) (context.Context, string) {
+	t.Helper()
+	var orgID string
+	if err := a.DB.QueryRow(context.Background(),
+		`INSERT INTO organizations (name) VALUES ('Test') RETURNING id`).Scan(&orgID); err != nil {
+		t.Fatal(err)
+	}
+	ctx := withTenant(context.Background(), orgID)
+
+	host := User{DisplayName: "Host", Timezone: "UTC", Locale: "en"}
+	if err := a.CreateUser(ctx, &host, orgID); err != nil {
+		t.Fatal(err)
+	}
+	rule := AvailabilityRule{
+		UserID:         host.ID,
+		DayOfWeek:      int(day.Weekday()),
+		StartTime:      "09:00",
+		EndTime:        "12:00",
+		SlotLengthMins: 60,
+		Timezone:       "UTC",
+		Available:      true,
+	}
+	if err := a.InsertAvailabilityRule(ctx, &rule); err != nil {
+		t.Fatal(err)
+	}
+	return ctx, host.ID
+}
+
+// bookingDay is a day a week out, so it's within any notice and horizon.
+func bookingDay() time.Time {
+	return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 7)
+}
+
+func slotStarts(slots []Slot) []string {
+	var out []string
+	for _, s := range slots {
+		out = append(out, s.StartUTC.Format("15:04"))
+	}
+	return out
+}
+
+func TestBusyPeriodRemovesSlots(t *testing.T) {
+	a := testApp(t)
+	day := bookingDay()
+	ctx, hostID := seedHost(t, a, day)
+
+	slots, err := a.GenerateAvailableSlots(ctx, hostID, day, day.Add(24*time.Hour), SlotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(slotStarts(slots), ","); got != "09:00,10:00,11:00" {
+		t.Fatalf("slots without busy time = %s", got)
+	}
+
+	// half an hour of busy time takes out the whole slot it overlaps
+	a.Busy = &fakeBusy{periods: []BusyPeriod{{Start: day.Add(10 * time.Hour), End: day.Add(10*time.Hour + 30*time.Minute)}}}
+	slots, err = a.GenerateAvailableSlots(ctx, hostID, day, day.Add(24*time.Hour), SlotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(slotStarts(slots), ","); got != "09:00,11:00" {
+		t.Errorf("slots around busy time = %s, want 09:00,11:00", got)
+	}
+
+	a.Busy = &fakeBusy{err: errors.New("provider down")}
+	if _, err := a.GenerateAvailableSlots(ctx, hostID, day, day.Add(24*time.Hour), SlotOptions{}); !errors.Is(err, errCalendarUnavailable) {
+		t.Errorf("err = %v, want errCalendarUnavailable", err)
+	}
+}
+
+func TestBusyPeriodRejectsBooking(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := testApp(t)
+	day := bookingDay()
+	ctx, hostID := seedHost(t, a, day)
+	a.Busy = &fakeBusy{periods: []BusyPeriod{{Start: day.Add(10 * time.Hour), End: day.Add(10*time.Hour + 30*time.Minute)}}}
+
+	r := gin.New()
+	r.POST("/users/:id/bookings", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(withTenant(c.Request.Context(), tenantFrom(ctx)))
+	}, a.CreateBookingHandler)
+
+	book := func(start time.Time) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{
+			"candidate_email": "guest@example.com",
+			"start_at_utc":    start.Format(time.RFC3339),
+			"end_at_utc":      start.Add(time.Hour).Format(time.RFC3339),
+		})
+		req := httptest.NewRequest(http.MethodPost, "/users/"+hostID+"/bookings", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := book(day.Add(10 * time.Hour)); w.Code != http.StatusConflict ||
+		!strings.Contains(w.Body.String(), "conflicts with the host's calendar") {
+		t.Errorf("booking over busy time: %d %s", w.Code, w.Body)
+	}
+	if w := book(day.Add(11 * time.Hour)); w.Code != http.StatusCreated {
+		t.Errorf("booking a free slot: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestConflictsWithBusy(t *testing.T) {
+	busy := spans(t, "10:00-11:00")
+	tests := []struct {
+		name          string
+		slot          string
+		before, after time.Duration
+		want          bool
+	}{
+		{"overlap", "10:30-11:30", 0, 0, true},
+		{"inside", "10:15-10:45", 0, 0, true},
+		{"back to back before", "09:00-10:00", 0, 0, false},
+		{"back to back after", "11:00-12:00", 0, 0, false},
+		{"buffer after reaches busy time", "08:00-09:00", 0, 61 * time.Minute, true},
+		{"buffer after ends at busy time", "08:00-09:00", 0, time.Hour, false},
+		{"buffer before reaches busy time", "11:00-12:00", time.Minute, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot := spans(t, tt.slot)[0]
+			if got := conflictsWithBusy(slot, tt.before, tt.after, busy); got != tt.want {
+				t.Errorf("conflictsWithBusy(%s, %s, %s) = %v, want %v", tt.slot, tt.before, tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package app
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,16 +24,33 @@ type GoogleCalendarConfig struct {
 
 // CalendarEvent represents a Google Calendar event
 type CalendarEvent struct {
-	ID             string          `json:"id"`
-	Summary        string          `json:"summary"`
-	Description    string          `json:"description,omitempty"`
-	StartTime      time.Time       `json:"start_time"`
-	EndTime        time.Time       `json:"end_time"`
-	Location       string          `json:"location,omitempty"`
-	Status         string          `json:"status"`
-	Creator        string          `json:"creator,omitempty"`
-	MeetingLink    string          `json:"meeting_link,omitempty"`
-	ConferenceData *ConferenceInfo `json:"conference_data,omitempty"`
+	ID               string          `json:"id"`
+	CalendarID       string          `json:"calendar_id"`
+	Summary          string          `json:"summary"`
+	Description      string          `json:"description,omitempty"`
+	StartTime        time.Time       `json:"start_time"`
+	EndTime          time.Time       `json:"end_time"`
+	AllDay           bool            `json:"all_day"`
+	StartDate        string          `json:"start_date,omitempty"` // all-day events: first day, YYYY-MM-DD
+	EndDate          string          `json:"end_date,omitempty"`   // all-day events: day after the last, YYYY-MM-DD
+	TimeZone         string          `json:"time_zone,omitempty"`  // IANA zone the event was scheduled in
+	Location         string          `json:"location,omitempty"`
+	Status           string          `json:"status"`
+	Creator          string          `json:"creator,omitempty"`
+	Attendees        []EventAttendee `json:"attendees,omitempty"`
+	RecurringEventID string          `json:"recurring_event_id,omitempty"` // set on instances of a recurring event
+	MeetingLink      string          `json:"meeting_link,omitempty"`
+	ConferenceData   *ConferenceInfo `json:"conference_data,omitempty"`
+}
+
+// EventAttendee is one invitee of a calendar event
+type EventAttendee struct {
+	Email          string `json:"email"`
+	DisplayName    string `json:"display_name,omitempty"`
+	ResponseStatus string `json:"response_status"` // "needsAction", "declined", "tentative" or "accepted"
+	Organizer      bool   `json:"organizer,omitempty"`
+	Optional       bool   `json:"optional,omitempty"`
+	Self           bool   `json:"self,omitempty"`
 }
 
 // ConferenceInfo represents meeting/conference details
@@ -61,6 +80,9 @@ func InitGoogleCalendarConfig() *GoogleCalendarConfig {
 		},
 		Endpoint: google.Endpoint,
 	}
+	if googleWriteEnabled() {
+		config.Scopes = append(config.Scopes, calendar.CalendarEventsScope)
+	}
 
 	return &GoogleCalendarConfig{Config: config}
 }
@@ -73,13 +95,33 @@ func (a *App) GoogleAuthHandler(c *gin.Context) {
 		return
 	}
 
-	// Generate state parameter for security
-	state := fmt.Sprintf("user_%s_%d", c.Query("user_id"), time.Now().Unix())
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
+		return
+	}
 
-	url := calendarConfig.Config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	// Server-stored, single-use state bound to the user, with a PKCE verifier.
+	// The browser opening auth_url is bound to it before going on to Google.
+	state, err := a.createOAuthState(c.Request.Context(), userID, providerGoogle)
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start authorization"})
+		return
+	}
+
+	url, err := oauthStartURL(calendarConfig.Config.RedirectURL, state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid redirect URL"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"auth_url": url,
-		"state":    state,
+		"auth_url":   url,
+		"state":      state,
+		"expires_in": int(oauthStateTTL.Seconds()),
 	})
 }
 
@@ -91,43 +133,81 @@ func (a *App) GoogleOAuth2CallbackHandler(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
 	code := c.Query("code")
 	state := c.Query("state")
 
+	// Verify state before anything else; it is consumed even if the user declined
+	pending, err := a.consumeOAuthState(ctx, state, takeOAuthBrowserCookie(c), providerGoogle)
+	if errors.Is(err, errInvalidOAuthState) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify state"})
+		return
+	}
+
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorization denied: " + reason})
+		return
+	}
 	if code == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "authorization code required"})
 		return
 	}
 
 	// Exchange code for token
-	token, err := calendarConfig.Config.Exchange(context.Background(), code)
+	token, err := calendarConfig.Config.Exchange(ctx, code, oauth2.VerifierOption(pending.Verifier))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to exchange code for token"})
 		return
 	}
 
-	// Store token (in a real app, you'd store this in database associated with user)
-	tokenJSON, _ := json.Marshal(token)
+	// Store token server-side; it is never returned to the caller. The state,
+	// not a tenant, authorizes this, so it's written as system work.
+	if err := a.SaveCalendarConnection(asSystem(ctx), pending.UserID, providerGoogle, token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store calendar connection"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Authorization successful",
-		"state":   state,
-		"token":   string(tokenJSON), // In production, don't return token directly
+		"message":  "Authorization successful",
+		"user_id":  pending.UserID,
+		"provider": providerGoogle,
 	})
 }
 
-// GetGoogleCalendarEvents fetches events from Google Calendar
-func (a *App) GetGoogleCalendarEvents(c *gin.Context) {
-	// Get token from request (in production, get from database)
-	tokenStr := c.GetHeader("X-Google-Token")
-	if tokenStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Google token required in X-Google-Token header"})
+// googleCalendarService builds a Calendar client from the user's stored connection.
+// Refreshed tokens are written back to calendar_connections.
+func (a *App) googleCalendarService(ctx context.Context, cfg *GoogleCalendarConfig, userID string) (*calendar.Service, error) {
+	conn, err := a.GetCalendarConnection(ctx, userID, providerGoogle)
+	if err != nil {
+		return nil, err
+	}
+	client := oauth2.NewClient(ctx, a.tokenSource(ctx, cfg.Config, conn))
+	return calendar.NewService(ctx, option.WithHTTPClient(client))
+}
+
+// respondCalendarServiceError maps failures to open a calendar connection to responses.
+func respondCalendarServiceError(c *gin.Context, err error) {
+	if errors.Is(err, errNoCalendarConnection) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not connected"})
+		return
+	}
+	if errors.Is(err, errProviderNotConfigured) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create calendar service"})
+}
 
-	var token oauth2.Token
-	if err := json.Unmarshal([]byte(tokenStr), &token); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token format"})
+// GetGoogleCalendarEvents fetches events from Google Calendar, following
+// every result page. Logs carry IDs and counts only, never event contents.
+func (a *App) GetGoogleCalendarEvents(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
 		return
 	}
 
@@ -137,184 +217,224 @@ func (a *App) GetGoogleCalendarEvents(c *gin.Context) {
 		return
 	}
 
-	// Create HTTP client with token
-	client := calendarConfig.Config.Client(context.Background(), &token)
-
-	// Create Calendar service
-	srv, err := calendar.NewService(context.Background(), option.WithHTTPClient(client))
+	// Create Calendar service from the stored connection
+	ctx := c.Request.Context()
+	srv, err := a.googleCalendarService(ctx, calendarConfig, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create calendar service"})
+		respondCalendarServiceError(c, err)
 		return
 	}
 
-	// Parse query parameters
-	calendarID := c.DefaultQuery("calendar_id", "primary")
+	// Parse query parameters; without calendar_id, events come from the
+	// user's selected conflict calendars
+	calendarIDs := []string{c.Query("calendar_id")}
+	if calendarIDs[0] == "" {
+		sel, err := a.GetCalendarSelection(ctx, userID, providerGoogle)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		calendarIDs = sel.ConflictCalendarIDs
+	}
 	timeMin := c.Query("time_min") // RFC3339 format
 	timeMax := c.Query("time_max") // RFC3339 format
-	maxResults := int64(250)
+	pageSize := int64(250)
 
-	// Build the events call
-	eventsCall := srv.Events.List(calendarID).
-		SingleEvents(true).
-		OrderBy("startTime").
-		MaxResults(maxResults)
+	var calendarEvents []CalendarEvent
+	for _, calendarID := range calendarIDs {
+		// Build the events call
+		eventsCall := srv.Events.List(calendarID).
+			SingleEvents(true).
+			OrderBy("startTime").
+			MaxResults(pageSize)
+
+		if timeMin != "" {
+			eventsCall = eventsCall.TimeMin(timeMin)
+		}
+		if timeMax != "" {
+			eventsCall = eventsCall.TimeMax(timeMax)
+		}
 
-	if timeMin != "" {
-		eventsCall = eventsCall.TimeMin(timeMin)
+		// Execute the call, page by page
+		pages, count := 0, 0
+		err := eventsCall.Pages(ctx, func(events *calendar.Events) error {
+			pages++
+			// all-day dates are local to the calendar's zone
+			calLoc, err := loadTimezone(events.TimeZone)
+			if err != nil {
+				calLoc = time.UTC
+			}
+			for _, item := range events.Items {
+				event, err := googleCalendarEvent(item, calendarID, calLoc)
+				if err != nil {
+					slog.Warn("skipping calendar event", "user_id", userID, "calendar_id", calendarID,
+						"event_id", item.Id, "error", err)
+					continue
+				}
+				calendarEvents = append(calendarEvents, event)
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			slog.Error("failed to list calendar events", "user_id", userID, "calendar_id", calendarID,
+				"pages", pages, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to retrieve events: %v", err)})
+			return
+		}
+		slog.Info("listed calendar events", "user_id", userID, "calendar_id", calendarID,
+			"events", count, "pages", pages)
 	}
-	if timeMax != "" {
-		eventsCall = eventsCall.TimeMax(timeMax)
+
+	// Merge calendars into one timeline
+	if len(calendarIDs) > 1 {
+		sort.SliceStable(calendarEvents, func(i, j int) bool {
+			return calendarEvents[i].StartTime.Before(calendarEvents[j].StartTime)
+		})
 	}
 
-	// Execute the call
-	events, err := eventsCall.Do()
+	c.JSON(http.StatusOK, gin.H{
+		"events": calendarEvents,
+		"count":  len(calendarEvents),
+	})
+}
+
+// googleCalendarEvent converts a Google event. calLoc is the calendar's zone,
+// which places all-day events and stands in for a missing event zone.
+func googleCalendarEvent(item *calendar.Event, calendarID string, calLoc *time.Location) (CalendarEvent, error) {
+	span, allDay, err := googleEventSpan(item, calLoc)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to retrieve events: %v", err)})
-		return
+		return CalendarEvent{}, err
 	}
 
-	// Convert to our format
-	var calendarEvents []CalendarEvent
-	for _, item := range events.Items {
-
-		str, _ := json.MarshalIndent(item, "", "")
-		fmt.Println("-----------------------------------------------------------------------")
-		fmt.Println("item: ", string(str))
-		event := CalendarEvent{
-			ID:          item.Id,
-			Summary:     item.Summary,
-			Description: item.Description,
-			Location:    item.Location,
-			Status:      item.Status,
-		}
+	event := CalendarEvent{
+		ID:               item.Id,
+		CalendarID:       calendarID,
+		Summary:          item.Summary,
+		Description:      item.Description,
+		StartTime:        span.Start,
+		EndTime:          span.End,
+		AllDay:           allDay,
+		TimeZone:         item.Start.TimeZone,
+		Location:         item.Location,
+		Status:           item.Status,
+		RecurringEventID: item.RecurringEventId,
+	}
+	if event.TimeZone == "" {
+		event.TimeZone = calLoc.String()
+	}
+	if allDay {
+		event.StartDate, event.EndDate = item.Start.Date, item.End.Date
+	}
 
-		// Handle creator
-		if item.Creator != nil {
-			event.Creator = item.Creator.Email
-		}
+	// Handle creator
+	if item.Creator != nil {
+		event.Creator = item.Creator.Email
+	}
 
-		// Extract meeting link (Google Meet link)
-		if item.HangoutLink != "" {
-			event.MeetingLink = item.HangoutLink
-		}
+	for _, att := range item.Attendees {
+		event.Attendees = append(event.Attendees, EventAttendee{
+			Email:          att.Email,
+			DisplayName:    att.DisplayName,
+			ResponseStatus: att.ResponseStatus,
+			Organizer:      att.Organizer,
+			Optional:       att.Optional,
+			Self:           att.Self,
+		})
+	}
 
-		// Extract detailed conference data
-		if item.ConferenceData != nil && len(item.ConferenceData.EntryPoints) > 0 {
-			conferenceInfo := &ConferenceInfo{}
+	// Extract meeting link (Google Meet link)
+	if item.HangoutLink != "" {
+		event.MeetingLink = item.HangoutLink
+	}
 
-			// Get conference type
-			if item.ConferenceData.ConferenceSolution != nil {
-				conferenceInfo.Type = item.ConferenceData.ConferenceSolution.Name
-			}
+	// Extract detailed conference data
+	if item.ConferenceData != nil && len(item.ConferenceData.EntryPoints) > 0 {
+		conferenceInfo := &ConferenceInfo{}
 
-			// Get meeting ID
-			if item.ConferenceData.ConferenceId != "" {
-				conferenceInfo.ID = item.ConferenceData.ConferenceId
-			}
+		// Get conference type
+		if item.ConferenceData.ConferenceSolution != nil {
+			conferenceInfo.Type = item.ConferenceData.ConferenceSolution.Name
+		}
 
-			// Extract entry points (URLs and phone numbers)
-			var phoneNumbers []string
-			for _, entryPoint := range item.ConferenceData.EntryPoints {
-				switch entryPoint.EntryPointType {
-				case "video":
-					if conferenceInfo.URL == "" && entryPoint.Uri != "" {
-						conferenceInfo.URL = entryPoint.Uri
-						// If no HangoutLink, use this as meeting link
-						if event.MeetingLink == "" {
-							event.MeetingLink = entryPoint.Uri
-						}
-					}
-				case "phone":
-					if entryPoint.Uri != "" {
-						phoneNumbers = append(phoneNumbers, entryPoint.Uri)
+		// Get meeting ID
+		if item.ConferenceData.ConferenceId != "" {
+			conferenceInfo.ID = item.ConferenceData.ConferenceId
+		}
+
+		// Extract entry points (URLs and phone numbers)
+		var phoneNumbers []string
+		for _, entryPoint := range item.ConferenceData.EntryPoints {
+			switch entryPoint.EntryPointType {
+			case "video":
+				if conferenceInfo.URL == "" && entryPoint.Uri != "" {
+					conferenceInfo.URL = entryPoint.Uri
+					// If no HangoutLink, use this as meeting link
+					if event.MeetingLink == "" {
+						event.MeetingLink = entryPoint.Uri
 					}
-				case "more":
-					// Additional meeting details
-					if entryPoint.Uri != "" && conferenceInfo.URL == "" {
-						conferenceInfo.URL = entryPoint.Uri
-						if event.MeetingLink == "" {
-							event.MeetingLink = entryPoint.Uri
-						}
+				}
+			case "phone":
+				if entryPoint.Uri != "" {
+					phoneNumbers = append(phoneNumbers, entryPoint.Uri)
+				}
+			case "more":
+				// Additional meeting details
+				if entryPoint.Uri != "" && conferenceInfo.URL == "" {
+					conferenceInfo.URL = entryPoint.Uri
+					if event.MeetingLink == "" {
+						event.MeetingLink = entryPoint.Uri
 					}
 				}
 			}
-
-			if len(phoneNumbers) > 0 {
-				conferenceInfo.PhoneNumbers = phoneNumbers
-			}
-
-			// Only include conference data if we have meaningful info
-			if conferenceInfo.URL != "" || conferenceInfo.ID != "" || len(conferenceInfo.PhoneNumbers) > 0 {
-				event.ConferenceData = conferenceInfo
-			}
 		}
 
-		// Parse start time
-		if item.Start.DateTime != "" {
-			if startTime, err := time.Parse(time.RFC3339, item.Start.DateTime); err == nil {
-				event.StartTime = startTime
-			}
-		} else if item.Start.Date != "" {
-			if startTime, err := time.Parse("2006-01-02", item.Start.Date); err == nil {
-				event.StartTime = startTime
-			}
+		if len(phoneNumbers) > 0 {
+			conferenceInfo.PhoneNumbers = phoneNumbers
 		}
 
-		// Parse end time
-		if item.End.DateTime != "" {
-			if endTime, err := time.Parse(time.RFC3339, item.End.DateTime); err == nil {
-				event.EndTime = endTime
-			}
-		} else if item.End.Date != "" {
-			if endTime, err := time.Parse("2006-01-02", item.End.Date); err == nil {
-				event.EndTime = endTime
-			}
+		// Only include conference data if we have meaningful info
+		if conferenceInfo.URL != "" || conferenceInfo.ID != "" || len(conferenceInfo.PhoneNumbers) > 0 {
+			event.ConferenceData = conferenceInfo
 		}
-
-		calendarEvents = append(calendarEvents, event)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"events": calendarEvents,
-		"count":  len(calendarEvents),
-	})
+	return event, nil
 }
 
-// GetGoogleCalendarList fetches available calendars
-func (a *App) GetGoogleCalendarList(c *gin.Context) {
-	// Get token from request
-	tokenStr := c.GetHeader("X-Google-Token")
-	if tokenStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Google token required in X-Google-Token header"})
+// GetCalendarList fetches the calendars of a connected provider, flagged with
+// the user's selection
+func (a *App) GetCalendarList(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
 		return
 	}
-
-	var token oauth2.Token
-	if err := json.Unmarshal([]byte(tokenStr), &token); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token format"})
+	provider, ok := providerFromQuery(c)
+	if !ok {
 		return
 	}
 
-	calendarConfig := InitGoogleCalendarConfig()
-	if calendarConfig == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Google Calendar not configured"})
+	// Open the provider from the stored connection
+	ctx := c.Request.Context()
+	p, err := a.calendarProvider(ctx, userID, provider)
+	if err != nil {
+		respondCalendarServiceError(c, err)
 		return
 	}
 
-	// Create HTTP client with token
-	client := calendarConfig.Config.Client(context.Background(), &token)
-
-	// Create Calendar service
-	srv, err := calendar.NewService(context.Background(), option.WithHTTPClient(client))
+	// Get calendar list
+	calendarList, err := p.ListCalendars(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create calendar service"})
+		slog.Error("failed to list calendars", "user_id", userID, "provider", provider, "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to retrieve calendars"})
 		return
 	}
 
-	// Get calendar list
-	calendarList, err := srv.CalendarList.List().Do()
+	// Mark the calendars the user selected for conflicts and new events
+	sel, err := a.GetCalendarSelection(ctx, userID, provider)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to retrieve calendars: %v", err)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -324,35 +444,37 @@ func (a *App) GetGoogleCalendarList(c *gin.Context) {
 		Description string `json:"description,omitempty"`
 		Primary     bool   `json:"primary"`
 		AccessRole  string `json:"access_role"`
+		Conflict    bool   `json:"conflict"`
+		Target      bool   `json:"target"`
 	}
 
 	var calendars []CalendarInfo
-	for _, item := range calendarList.Items {
+	for _, item := range calendarList {
 		calendar := CalendarInfo{
-			ID:          item.Id,
-			Summary:     item.Summary,
+			ID:          item.ID,
+			Summary:     item.Name,
 			Description: item.Description,
 			Primary:     item.Primary,
 			AccessRole:  item.AccessRole,
+			Conflict:    selectionIncludes(sel.ConflictCalendarIDs, item.ID, item.Primary),
+			Target:      sel.TargetCalendarID != "" && selectionIncludes([]string{sel.TargetCalendarID}, item.ID, item.Primary),
 		}
 		calendars = append(calendars, calendar)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
+		"provider":  provider,
 		"calendars": calendars,
 		"count":     len(calendars),
 	})
 }
 
-// RefreshGoogleToken refreshes an expired Google OAuth token
+// RefreshGoogleToken forces a refresh of the user's stored Google token.
+// The new token is persisted server-side; only its expiry is returned.
 func (a *App) RefreshGoogleToken(c *gin.Context) {
-	// Get refresh token from request body
-	var requestBody struct {
-		RefreshToken string `json:"refresh_token" binding:"required"`
-	}
-
-	if err := c.ShouldBindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token required"})
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
 		return
 	}
 
@@ -362,23 +484,27 @@ func (a *App) RefreshGoogleToken(c *gin.Context) {
 		return
 	}
 
-	// Create token with refresh token
-	token := &oauth2.Token{
-		RefreshToken: requestBody.RefreshToken,
+	ctx := c.Request.Context()
+	conn, err := a.GetCalendarConnection(ctx, userID, providerGoogle)
+	if err != nil {
+		respondCalendarServiceError(c, err)
+		return
+	}
+	if conn.Token.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no refresh token stored; reconnect Google Calendar"})
+		return
 	}
 
-	// Use token source to get new token
-	tokenSource := calendarConfig.Config.TokenSource(context.Background(), token)
-	newToken, err := tokenSource.Token()
+	// mark the access token expired so the token source refreshes it
+	conn.Token.Expiry = time.Now().Add(-time.Minute)
+	newToken, err := a.tokenSource(ctx, calendarConfig.Config, conn).Token()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to refresh token"})
 		return
 	}
 
-	// Return new token
-	tokenJSON, _ := json.Marshal(newToken)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Token refreshed successfully",
-		"token":   string(tokenJSON),
+		"expiry":  newToken.Expiry,
 	})
 }
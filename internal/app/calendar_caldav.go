@@ -0,0 +1,595 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// caldavBookingProperty links an event we created back to its booking.
+const caldavBookingProperty = "X-SCHEDULER-BOOKING-ID"
+
+// errCalDAVUnauthorized is returned when the server rejects the stored credentials.
+var errCalDAVUnauthorized = errors.New("caldav: authentication failed")
+
+// caldavAllowHTTP reports whether CALDAV_ALLOW_HTTP permits plain-HTTP servers,
+// e.g. a local server in tests. Otherwise credentials only travel over HTTPS.
+func caldavAllowHTTP() bool {
+	allowed, _ := strconv.ParseBool(os.Getenv("CALDAV_ALLOW_HTTP"))
+	return allowed
+}
+
+// caldavCredentials are the sealed contents of a CalDAV connection.
+type caldavCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// parseCalDAVServerURL validates a CalDAV server address.
+func parseCalDAVServerURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return nil, errors.New("invalid server_url")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && caldavAllowHTTP()) {
+		return nil, errors.New("server_url must use https")
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return u, nil
+}
+
+// SaveCalDAVConnection stores CalDAV credentials for userID. The username and
+// password are sealed like OAuth tokens; the server URL is stored in clear.
+func (a *App) SaveCalDAVConnection(ctx context.Context, userID, serverURL string, creds caldavCredentials) error {
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	sealed, err := tc.seal(string(raw), connectionAAD(userID, providerCalDAV))
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO calendar_connections
+          (id, user_id, provider, access_token, token_type, server_url, created_at, updated_at)
+          VALUES (gen_random_uuid(), $1, $2, $3, 'basic', $4, now(), now())
+          ON CONFLICT (user_id, provider) DO UPDATE
+          SET access_token=EXCLUDED.access_token,
+              refresh_token=NULL,
+              token_type=EXCLUDED.token_type,
+              server_url=EXCLUDED.server_url,
+              updated_at=now()`
+	_, err = a.db(ctx).Exec(ctx, q, userID, providerCalDAV, sealed, serverURL)
+	return err
+}
+
+// caldavProvider opens userID's stored CalDAV connection.
+func (a *App) caldavProvider(ctx context.Context, userID string) (CalendarProvider, error) {
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sealed    []byte
+		serverURL string
+	)
+	err = a.db(ctx).QueryRow(ctx, `SELECT access_token, COALESCE(server_url,'') FROM calendar_connections
+	                          WHERE user_id=$1 AND provider=$2`, userID, providerCalDAV).Scan(&sealed, &serverURL)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errNoCalendarConnection
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := tc.open(sealed, connectionAAD(userID, providerCalDAV))
+	if err != nil {
+		return nil, err
+	}
+	var creds caldavCredentials
+	if err := json.Unmarshal([]byte(raw), &creds); err != nil {
+		return nil, err
+	}
+	base, err := parseCalDAVServerURL(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	return newCalDAVProvider(base, creds), nil
+}
+
+// caldavClient is a CalendarProvider speaking CalDAV (RFC 4791) with basic auth.
+// Calendar IDs are collection paths and event IDs are resource paths on the server.
+type caldavClient struct {
+	http  *http.Client
+	base  *url.URL
+	creds caldavCredentials
+}
+
+func newCalDAVProvider(base *url.URL, creds caldavCredentials) *caldavClient {
+	return &caldavClient{
+		http:  publicHTTPClient("caldav"),
+		base:  base,
+		creds: creds,
+	}
+}
+
+// resolve turns a server-relative href into a URL, refusing other hosts so
+// credentials are never sent elsewhere.
+func (c *caldavClient) resolve(href string) (*url.URL, error) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return nil, fmt.Errorf("caldav: invalid href %q", href)
+	}
+	u := c.base.ResolveReference(ref)
+	if u.Scheme != c.base.Scheme || u.Host != c.base.Host {
+		return nil, fmt.Errorf("caldav: href %q is on another server", href)
+	}
+	return u, nil
+}
+
+// do sends a request to href and returns the response if its status is one of want.
+func (c *caldavClient) do(ctx context.Context, method, href string, header http.Header, body []byte, want ...int) (*http.Response, error) {
+	u, err := c.resolve(href)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.SetBasicAuth(c.creds.Username, c.creds.Password)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range want {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, errCalDAVUnauthorized
+	}
+	return nil, fmt.Errorf("caldav: %s %s: %s", method, u.Path, resp.Status)
+}
+
+// davMultistatus is a WebDAV 207 response body.
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"DAV: prop"`
+	Status string  `xml:"DAV: status"`
+}
+
+type davProp struct {
+	CurrentUserPrincipal davHref         `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      davHref         `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	ResourceType         davResourceType `xml:"DAV: resourcetype"`
+	DisplayName          string          `xml:"DAV: displayname"`
+	Description          string          `xml:"urn:ietf:params:xml:ns:caldav calendar-description"`
+	Privileges           []davPrivilege  `xml:"DAV: current-user-privilege-set>privilege"`
+	ETag                 string          `xml:"DAV: getetag"`
+	CalendarData         string          `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
+
+type davResourceType struct {
+	Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+}
+
+type davPrivilege struct {
+	All          *struct{} `xml:"DAV: all"`
+	Write        *struct{} `xml:"DAV: write"`
+	WriteContent *struct{} `xml:"DAV: write-content"`
+	Bind         *struct{} `xml:"DAV: bind"`
+}
+
+// props returns the properties the server reported with a 2xx status.
+func (r davResponse) props() []davProp {
+	var out []davProp
+	for _, ps := range r.Propstats {
+		if fields := strings.Fields(ps.Status); len(fields) < 2 || strings.HasPrefix(fields[1], "2") {
+			out = append(out, ps.Prop)
+		}
+	}
+	return out
+}
+
+// multistatus sends a PROPFIND or REPORT and decodes the 207 response.
+func (c *caldavClient) multistatus(ctx context.Context, method, href, depth, body string) (*davMultistatus, error) {
+	header := http.Header{
+		"Content-Type": {"application/xml; charset=utf-8"},
+		"Depth":        {depth},
+	}
+	resp, err := c.do(ctx, method, href, header, []byte(body), http.StatusMultiStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("caldav: decode %s response: %w", method, err)
+	}
+	return &ms, nil
+}
+
+// propfindHref reads one href-valued property of href, or "" if it is absent.
+func (c *caldavClient) propfindHref(ctx context.Context, href, prop string, pick func(davProp) string) (string, error) {
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop>` + prop + `</d:prop></d:propfind>`
+	ms, err := c.multistatus(ctx, "PROPFIND", href, "0", body)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range ms.Responses {
+		for _, p := range r.props() {
+			if v := strings.TrimSpace(pick(p)); v != "" {
+				return v, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// calendarHome discovers the calendar home set, starting from the server URL.
+// Servers that skip a discovery step are assumed to serve it at the URL itself.
+func (c *caldavClient) calendarHome(ctx context.Context) (string, error) {
+	principal, err := c.propfindHref(ctx, c.base.Path, "<d:current-user-principal/>",
+		func(p davProp) string { return p.CurrentUserPrincipal.Href })
+	if err != nil {
+		return "", err
+	}
+	if principal == "" {
+		principal = c.base.Path
+	}
+	home, err := c.propfindHref(ctx, principal, "<c:calendar-home-set/>",
+		func(p davProp) string { return p.CalendarHomeSet.Href })
+	if err != nil {
+		return "", err
+	}
+	if home == "" {
+		home = principal
+	}
+	return home, nil
+}
+
+func (c *caldavClient) ListCalendars(ctx context.Context) ([]ProviderCalendar, error) {
+	home, err := c.calendarHome(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop>
+<d:resourcetype/><d:displayname/><c:calendar-description/><d:current-user-privilege-set/>
+</d:prop></d:propfind>`
+	ms, err := c.multistatus(ctx, "PROPFIND", home, "1", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []ProviderCalendar
+	for _, r := range ms.Responses {
+		var (
+			pc       = ProviderCalendar{ID: r.Href, AccessRole: "reader"}
+			calendar bool
+		)
+		for _, p := range r.props() {
+			if p.ResourceType.Calendar != nil {
+				calendar = true
+			}
+			if p.DisplayName != "" {
+				pc.Name = p.DisplayName
+			}
+			if p.Description != "" {
+				pc.Description = p.Description
+			}
+			for _, priv := range p.Privileges {
+				if priv.All != nil || priv.Write != nil || priv.WriteContent != nil || priv.Bind != nil {
+					pc.AccessRole = "writer"
+				}
+			}
+		}
+		if !calendar {
+			continue
+		}
+		if pc.Name == "" {
+			pc.Name = path.Base(strings.TrimSuffix(r.Href, "/"))
+		}
+		out = append(out, pc)
+	}
+
+	// CalDAV has no primary calendar; treat the first one as the main calendar
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	if len(out) > 0 {
+		out[0].Primary = true
+	}
+	return out, nil
+}
+
+// calendarPath resolves the primary alias to the main calendar's collection.
+func (c *caldavClient) calendarPath(ctx context.Context, calendarID string) (string, error) {
+	if calendarID != defaultCalendarID {
+		return calendarID, nil
+	}
+	calendars, err := c.ListCalendars(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(calendars) == 0 {
+		return "", errors.New("caldav: account has no calendars")
+	}
+	return calendars[0].ID, nil
+}
+
+func (c *caldavClient) ListEvents(ctx context.Context, calendarID string, from, to time.Time) ([]ProviderEvent, error) {
+	collection, err := c.calendarPath(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	// ask the server to expand recurrences into instances within the range
+	start, end := formatICalUTC(from), formatICalUTC(to)
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+<d:prop><d:getetag/><c:calendar-data><c:expand start="` + start + `" end="` + end + `"/></c:calendar-data></d:prop>
+<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">
+<c:time-range start="` + start + `" end="` + end + `"/>
+</c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`
+	ms, err := c.multistatus(ctx, "REPORT", collection, "1", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []ProviderEvent
+	for _, r := range ms.Responses {
+		for _, p := range r.props() {
+			if p.CalendarData == "" {
+				continue
+			}
+			roots, err := parseICalendar(p.CalendarData)
+			if err != nil {
+				return nil, fmt.Errorf("caldav: %s: %w", r.Href, err)
+			}
+			for _, root := range roots {
+				for _, vevent := range root.children("VEVENT") {
+					start, end, allDay, err := icalEventSpan(vevent, time.UTC)
+					if err != nil {
+						continue
+					}
+					out = append(out, ProviderEvent{
+						ID:          r.Href,
+						CalendarID:  collection,
+						Summary:     vevent.text("SUMMARY"),
+						Description: vevent.text("DESCRIPTION"),
+						Location:    vevent.text("LOCATION"),
+						Status:      strings.ToLower(vevent.text("STATUS")),
+						Start:       start,
+						End:         end,
+						AllDay:      allDay,
+						Transparent: strings.EqualFold(vevent.text("TRANSP"), "TRANSPARENT"),
+						BookingID:   vevent.text(caldavBookingProperty),
+					})
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+// FreeBusy derives busy time from events, since free-busy-query REPORT support
+// varies between servers. Free, cancelled and booking-mirrored events don't count.
+func (c *caldavClient) FreeBusy(ctx context.Context, calendarIDs []string, from, to time.Time) ([]BusyPeriod, error) {
+	var out []BusyPeriod
+	for _, id := range calendarIDs {
+		events, err := c.ListEvents(ctx, id, from, to)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, eventBusy(events)...)
+	}
+	return out, nil
+}
+
+func (c *caldavClient) CreateEvent(ctx context.Context, calendarID string, in EventInput) (string, error) {
+	collection, err := c.calendarPath(ctx, calendarID)
+	if err != nil {
+		return "", err
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	uid := hex.EncodeToString(raw)
+
+	vevent := &icalComponent{Name: "VEVENT"}
+	vevent.set("UID", uid, nil)
+	vevent.set("DTSTAMP", formatICalUTC(time.Now()), nil)
+	vevent.set("DTSTART", formatICalUTC(in.Start), nil)
+	vevent.set("DTEND", formatICalUTC(in.End), nil)
+	vevent.set("SUMMARY", escapeICalText(in.Summary), nil)
+	if in.Description != "" {
+		vevent.set("DESCRIPTION", escapeICalText(in.Description), nil)
+	}
+	if in.Location != "" {
+		vevent.set("LOCATION", escapeICalText(in.Location), nil)
+	}
+	for _, email := range in.Attendees {
+		vevent.Props = append(vevent.Props, icalProperty{
+			Name:   "ATTENDEE",
+			Params: map[string]string{"RSVP": "TRUE", "PARTSTAT": "NEEDS-ACTION"},
+			Value:  "mailto:" + email,
+		})
+	}
+	if in.BookingID != "" {
+		vevent.set(caldavBookingProperty, escapeICalText(in.BookingID), nil)
+	}
+	vcal := &icalComponent{Name: "VCALENDAR", Components: []*icalComponent{vevent}}
+	vcal.set("VERSION", "2.0", nil)
+	vcal.set("PRODID", "-//scheduler-service//EN", nil)
+
+	href := strings.TrimSuffix(collection, "/") + "/" + uid + ".ics"
+	header := http.Header{
+		"Content-Type":  {"text/calendar; charset=utf-8"},
+		"If-None-Match": {"*"},
+	}
+	resp, err := c.do(ctx, http.MethodPut, href, header, []byte(vcal.encode()),
+		http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return href, nil
+}
+
+func (c *caldavClient) UpdateEvent(ctx context.Context, calendarID, eventID string, start, end time.Time) error {
+	resp, err := c.do(ctx, http.MethodGet, eventID, nil, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	etag := resp.Header.Get("ETag")
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	roots, err := parseICalendar(string(data))
+	if err != nil {
+		return err
+	}
+	if len(roots) == 0 || len(roots[0].children("VEVENT")) == 0 {
+		return fmt.Errorf("caldav: %s has no event", eventID)
+	}
+	vevent := roots[0].children("VEVENT")[0]
+
+	sequence := 0
+	if p := vevent.get("SEQUENCE"); p != nil {
+		sequence, _ = strconv.Atoi(p.Value)
+	}
+	vevent.remove("DURATION")
+	vevent.set("DTSTART", formatICalUTC(start), nil)
+	vevent.set("DTEND", formatICalUTC(end), nil)
+	vevent.set("DTSTAMP", formatICalUTC(time.Now()), nil)
+	vevent.set("SEQUENCE", strconv.Itoa(sequence+1), nil)
+
+	header := http.Header{"Content-Type": {"text/calendar; charset=utf-8"}}
+	if etag != "" {
+		// fail rather than overwrite a concurrent edit
+		header.Set("If-Match", etag)
+	}
+	resp, err = c.do(ctx, http.MethodPut, eventID, header, []byte(roots[0].encode()),
+		http.StatusNoContent, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *caldavClient) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	resp, err := c.do(ctx, http.MethodDelete, eventID, nil, nil,
+		http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ConnectCalDAVHandler connects a CalDAV account after checking that the
+// credentials can list its calendars.
+// POST /api/calendar/caldav/connect
+func (a *App) ConnectCalDAVHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
+		return
+	}
+
+	var req struct {
+		ServerURL string `json:"server_url"`
+		Username  string `json:"username"`
+		Password  string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and password required"})
+		return
+	}
+	base, err := parseCalDAVServerURL(req.ServerURL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	creds := caldavCredentials{Username: req.Username, Password: req.Password}
+	calendars, err := newCalDAVProvider(base, creds).ListCalendars(ctx)
+	if errors.Is(err, errCalDAVUnauthorized) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to reach CalDAV server"})
+		return
+	}
+
+	if err := a.SaveCalDAVConnection(ctx, userID, base.String(), creds); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store calendar connection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "CalDAV connected",
+		"user_id":   userID,
+		"provider":  providerCalDAV,
+		"calendars": len(calendars),
+	})
+}
@@ -0,0 +1,375 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	caldavTestUser     = "alice"
+	caldavTestPassword = "secret"
+	caldavTestEvent    = "/dav/calendars/alice/work/standup.ics"
+)
+
+// fakeCalDAV serves a principal, its calendar home and one stored event, in
+// the shape of PROPFIND and REPORT responses real servers send.
+type fakeCalDAV struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	etag    string
+	event   string
+	report  string   // last REPORT body
+	ifMatch []string // If-Match of each PUT
+	putICS  []string
+	// editAfterGet simulates another client changing the event between our read and write.
+	editAfterGet bool
+}
+
+func newFakeCalDAV(t *testing.T) *fakeCalDAV {
+	f := &fakeCalDAV{
+		t:    t,
+		etag: `"v1"`,
+		event: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VEVENT\r\n" +
+			"UID:standup\r\nDTSTAMP:20260301T000000Z\r\nDTSTART:20260302T090000Z\r\nDURATION:PT30M\r\n" +
+			"SUMMARY:Standup\r\nSEQUENCE:2\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// client connects to the fake, which listens on loopback.
+func (f *fakeCalDAV) client(t *testing.T, path string) *caldavClient {
+	t.Setenv("ICS_ALLOW_PRIVATE_HOSTS", "true")
+	base, err := url.Parse(f.srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newCalDAVProvider(base, caldavCredentials{Username: caldavTestUser, Password: caldavTestPassword})
+}
+
+func (f *fakeCalDAV) serve(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != caldavTestUser || pass != caldavTestPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	depth := r.Header.Get("Depth")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/" && depth == "0":
+		if !strings.Contains(string(body), "current-user-principal") {
+			f.t.Errorf("principal PROPFIND body: %s", body)
+		}
+		f.multistatus(w, `<d:response><d:href>/dav/</d:href><d:propstat><d:prop>
+<d:current-user-principal><d:href>/dav/principals/alice/</d:href></d:current-user-principal>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/principals/alice/" && depth == "0":
+		if !strings.Contains(string(body), "calendar-home-set") {
+			f.t.Errorf("home set PROPFIND body: %s", body)
+		}
+		f.multistatus(w, `<d:response><d:href>/dav/principals/alice/</d:href><d:propstat><d:prop>
+<c:calendar-home-set><d:href>/dav/calendars/alice/</d:href></c:calendar-home-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+
+	case r.Method == "PROPFIND" && r.URL.Path == "/dav/calendars/alice/" && depth == "1":
+		f.multistatus(w, `
+<d:response><d:href>/dav/calendars/alice/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/></d:resourcetype><d:displayname>Home</d:displayname>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/alice/work/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:calendar/></d:resourcetype><d:displayname>Work</d:displayname>
+<c:calendar-description>Day job</c:calendar-description>
+<d:current-user-privilege-set><d:privilege><d:read/></d:privilege><d:privilege><d:write-content/></d:privilege></d:current-user-privilege-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/alice/holidays/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+<d:current-user-privilege-set><d:privilege><d:read/></d:privilege></d:current-user-privilege-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>
+<d:propstat><d:prop><d:displayname>ignored</d:displayname></d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/alice/team/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:calendar/></d:resourcetype><d:displayname>Team</d:displayname>
+<d:current-user-privilege-set><d:privilege><d:all/></d:privilege></d:current-user-privilege-set>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/alice/inbox/</d:href><d:propstat><d:prop>
+<d:resourcetype><d:collection/><c:schedule-inbox/></d:resourcetype><d:displayname>Inbox</d:displayname>
+</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+
+	case r.Method == "REPORT" && r.URL.Path == "/dav/calendars/alice/work/" && depth == "1":
+		f.report = string(body)
+		// an expanded weekly series, a free event, a cancelled one and a mirrored booking
+		f.multistatus(w, `
+<d:response><d:href>/dav/calendars/alice/work/weekly.ics</d:href><d:propstat><d:prop>
+<d:getetag>"w1"</d:getetag><c:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:weekly
+RECURRENCE-ID:20260302T130000Z
+DTSTART:20260302T130000Z
+DTEND:20260302T140000Z
+SUMMARY:Weekly sync
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly
+RECURRENCE-ID:20260309T130000Z
+DTSTART:20260309T130000Z
+DTEND:20260309T140000Z
+SUMMARY:Weekly sync
+END:VEVENT
+END:VCALENDAR
+</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/alice/work/focus.ics</d:href><d:propstat><d:prop>
+<d:getetag>"f1"</d:getetag><c:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:focus
+DTSTART:20260303T100000Z
+DTEND:20260303T120000Z
+SUMMARY:Focus
+TRANSP:TRANSPARENT
+END:VEVENT
+END:VCALENDAR
+</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/alice/work/dropped.ics</d:href><d:propstat><d:prop>
+<d:getetag>"d1"</d:getetag><c:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:dropped
+DTSTART:20260304T100000Z
+DTEND:20260304T110000Z
+STATUS:CANCELLED
+END:VEVENT
+END:VCALENDAR
+</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>
+<d:response><d:href>/dav/calendars/alice/work/booking.ics</d:href><d:propstat><d:prop>
+<d:getetag>"b1"</d:getetag><c:calendar-data>BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:booking
+DTSTART:20260305T150000Z
+DTEND:20260305T153000Z
+SUMMARY:Intro call
+X-SCHEDULER-BOOKING-ID:booking-42
+END:VEVENT
+END:VCALENDAR
+</c:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+
+	case r.Method == http.MethodGet && r.URL.Path == caldavTestEvent:
+		w.Header().Set("ETag", f.etag)
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		io.WriteString(w, f.event)
+		if f.editAfterGet {
+			f.etag = `"v1-edited"`
+		}
+
+	case r.Method == http.MethodPut && r.URL.Path == caldavTestEvent:
+		f.ifMatch = append(f.ifMatch, r.Header.Get("If-Match"))
+		f.putICS = append(f.putICS, string(body))
+		if match := r.Header.Get("If-Match"); match != "" && match != f.etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		f.event = string(body)
+		f.etag = `"v2"`
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		f.t.Errorf("unexpected %s %s (Depth %q)", r.Method, r.URL.Path, depth)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeCalDAV) multistatus(w http.ResponseWriter, responses string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`+responses+`</d:multistatus>`)
+}
+
+func TestCalDAVCalendarHome(t *testing.T) {
+	f := newFakeCalDAV(t)
+
+	home, err := f.client(t, "/dav/").calendarHome(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home != "/dav/calendars/alice/" {
+		t.Errorf("home = %q, want /dav/calendars/alice/", home)
+	}
+}
+
+func TestCalDAVWrongCredentials(t *testing.T) {
+	f := newFakeCalDAV(t)
+	c := f.client(t, "/dav/")
+	c.creds.Password = "wrong"
+
+	if _, err := c.ListCalendars(context.Background()); !errors.Is(err, errCalDAVUnauthorized) {
+		t.Errorf("err = %v, want errCalDAVUnauthorized", err)
+	}
+}
+
+func TestCalDAVListCalendars(t *testing.T) {
+	f := newFakeCalDAV(t)
+
+	calendars, err := f.client(t, "/dav/").ListCalendars(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sorted by path, the first one primary; plain collections and inboxes skipped
+	want := []ProviderCalendar{
+		{ID: "/dav/calendars/alice/holidays/", Name: "holidays", Primary: true, AccessRole: "reader"},
+		{ID: "/dav/calendars/alice/team/", Name: "Team", AccessRole: "writer"},
+		{ID: "/dav/calendars/alice/work/", Name: "Work", Description: "Day job", AccessRole: "writer"},
+	}
+	if len(calendars) != len(want) {
+		t.Fatalf("got %+v, want %+v", calendars, want)
+	}
+	for i := range want {
+		if calendars[i] != want[i] {
+			t.Errorf("calendar %d = %+v, want %+v", i, calendars[i], want[i])
+		}
+	}
+}
+
+func TestCalDAVListEventsExpandsRecurrences(t *testing.T) {
+	f := newFakeCalDAV(t)
+	c := f.client(t, "/dav/")
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(14 * 24 * time.Hour)
+
+	events, err := c.ListEvents(context.Background(), "/dav/calendars/alice/work/", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(f.report, `<c:expand start="20260302T000000Z" end="20260316T000000Z"/>`) ||
+		!strings.Contains(f.report, `<c:time-range start="20260302T000000Z" end="20260316T000000Z"/>`) {
+		t.Errorf("REPORT doesn't ask for the expanded range:\n%s", f.report)
+	}
+
+	if len(events) != 5 {
+		t.Fatalf("got %d events, want 5: %+v", len(events), events)
+	}
+	// sorted by start, so the two weekly instances bracket the others
+	first, last := events[0], events[4]
+	if first.ID != "/dav/calendars/alice/work/weekly.ics" || last.ID != first.ID {
+		t.Errorf("weekly instances = %q, %q", first.ID, last.ID)
+	}
+	if !first.Start.Equal(from.Add(13*time.Hour)) || !last.Start.Equal(from.Add(7*24*time.Hour+13*time.Hour)) {
+		t.Errorf("weekly instances start %v and %v", first.Start, last.Start)
+	}
+	if first.CalendarID != "/dav/calendars/alice/work/" || first.Summary != "Weekly sync" {
+		t.Errorf("weekly instance = %+v", first)
+	}
+	if !events[1].Transparent {
+		t.Errorf("TRANSP:TRANSPARENT event not transparent: %+v", events[1])
+	}
+	if events[2].Status != "cancelled" {
+		t.Errorf("cancelled event status = %q", events[2].Status)
+	}
+	if events[3].BookingID != "booking-42" {
+		t.Errorf("mirrored event BookingID = %q", events[3].BookingID)
+	}
+
+	busy, err := c.FreeBusy(context.Background(), []string{"/dav/calendars/alice/work/"}, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 2 || !busy[0].Start.Equal(first.Start) || !busy[1].Start.Equal(last.Start) {
+		t.Errorf("busy = %+v, want only the weekly instances", busy)
+	}
+}
+
+func TestCalDAVUpdateEventSendsIfMatch(t *testing.T) {
+	f := newFakeCalDAV(t)
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	if err := f.client(t, "/dav/").UpdateEvent(context.Background(), "/dav/calendars/alice/work/", caldavTestEvent,
+		start, start.Add(45*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.ifMatch) != 1 {
+		t.Fatalf("got %d PUTs, want 1", len(f.ifMatch))
+	}
+	if got := f.ifMatch[0]; got != `"v1"` {
+		t.Errorf("If-Match = %q, want the ETag read with the event", got)
+	}
+
+	ics := f.putICS[0]
+	for _, want := range []string{"UID:standup", "DTSTART:20260302T100000Z", "DTEND:20260302T104500Z", "SEQUENCE:3", "SUMMARY:Standup"} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("updated event lacks %s:\n%s", want, ics)
+		}
+	}
+	if strings.Contains(ics, "DURATION") {
+		t.Errorf("updated event keeps DURATION next to DTEND:\n%s", ics)
+	}
+}
+
+func TestCalDAVUpdateEventConcurrentEdit(t *testing.T) {
+	f := newFakeCalDAV(t)
+	f.editAfterGet = true
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	err := f.client(t, "/dav/").UpdateEvent(context.Background(), "/dav/calendars/alice/work/", caldavTestEvent,
+		start, start.Add(45*time.Minute))
+	if err == nil || !strings.Contains(err.Error(), "412") {
+		t.Errorf("err = %v, want a 412 precondition failure", err)
+	}
+	if !strings.Contains(f.event, "DURATION:PT30M") {
+		t.Errorf("concurrent edit overwritten:\n%s", f.event)
+	}
+}
+
+func TestCalDAVRefusesPrivateHosts(t *testing.T) {
+	f := newFakeCalDAV(t)
+	base, err := url.Parse(f.srv.URL + "/dav/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newCalDAVProvider(base, caldavCredentials{Username: caldavTestUser, Password: caldavTestPassword})
+	if _, err := c.ListCalendars(context.Background()); err == nil || !strings.Contains(err.Error(), "refusing to connect") {
+		t.Errorf("err = %v, want the loopback server refused", err)
+	}
+}
+
+func TestConnectCalDAVHidesUpstreamErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CALDAV_ALLOW_HTTP", "true")
+	t.Setenv("ICS_ALLOW_PRIVATE_HOSTS", "true")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "internal-secret-detail")
+	}))
+	t.Cleanup(upstream.Close)
+
+	r := gin.New()
+	r.POST("/connect", (&App{}).ConnectCalDAVHandler)
+	body := `{"server_url":"` + upstream.URL + `/dav/","username":"alice","password":"secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/connect?user_id=u1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "secret-detail") || strings.Contains(w.Body.String(), "/dav/") {
+		t.Errorf("upstream details leaked: %s", w.Body)
+	}
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/oauth2"
+)
+
+// Calendar providers stored in calendar_connections.provider
+const (
+	providerGoogle    = "google"
+	providerMicrosoft = "microsoft"
+	providerCalDAV    = "caldav"
+)
+
+// errNoCalendarConnection is returned when a user has not connected a provider.
+var errNoCalendarConnection = errors.New("calendar not connected")
+
+// CalendarConnection is a user's stored OAuth token for one provider.
+// The token never leaves the server.
+type CalendarConnection struct {
+	ID        string
+	UserID    string
+	Provider  string
+	Token     *oauth2.Token
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// connectionAAD binds sealed token fields to their owner.
+func connectionAAD(userID, provider string) string {
+	return userID + ":" + provider
+}
+
+// SaveCalendarConnection stores tok for userID and provider, encrypting the token
+// fields. A token without a refresh token keeps the previously stored one, since
+// providers usually only return it on the first consent.
+func (a *App) SaveCalendarConnection(ctx context.Context, userID, provider string, tok *oauth2.Token) error {
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return err
+	}
+	aad := connectionAAD(userID, provider)
+
+	access, err := tc.seal(tok.AccessToken, aad)
+	if err != nil {
+		return err
+	}
+	var refresh []byte
+	if tok.RefreshToken != "" {
+		if refresh, err = tc.seal(tok.RefreshToken, aad); err != nil {
+			return err
+		}
+	}
+
+	q := `INSERT INTO calendar_connections
+          (id, user_id, provider, access_token, refresh_token, token_type, expiry, created_at, updated_at)
+          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, now(), now())
+          ON CONFLICT (user_id, provider) DO UPDATE
+          SET access_token=EXCLUDED.access_token,
+              refresh_token=COALESCE(EXCLUDED.refresh_token, calendar_connections.refresh_token),
+              token_type=EXCLUDED.token_type,
+              expiry=EXCLUDED.expiry,
+              updated_at=now()`
+	_, err = a.db(ctx).Exec(ctx, q, userID, provider, access, refresh, tok.TokenType, nullIfZeroTime(tok.Expiry))
+	return err
+}
+
+// GetCalendarConnection loads and decrypts a user's connection, returning
+// errNoCalendarConnection when there is none.
+func (a *App) GetCalendarConnection(ctx context.Context, userID, provider string) (*CalendarConnection, error) {
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	q := `SELECT id, access_token, refresh_token, COALESCE(token_type,''), expiry, created_at, updated_at
+	      FROM calendar_connections WHERE user_id=$1 AND provider=$2`
+	var (
+		conn            = CalendarConnection{UserID: userID, Provider: provider}
+		access, refresh []byte
+		expiry          *time.Time
+		tok             oauth2.Token
+	)
+	err = a.db(ctx).QueryRow(ctx, q, userID, provider).Scan(&conn.ID, &access, &refresh, &tok.TokenType,
+		&expiry, &conn.CreatedAt, &conn.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errNoCalendarConnection
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aad := connectionAAD(userID, provider)
+	if tok.AccessToken, err = tc.open(access, aad); err != nil {
+		return nil, err
+	}
+	if len(refresh) > 0 {
+		if tok.RefreshToken, err = tc.open(refresh, aad); err != nil {
+			return nil, err
+		}
+	}
+	if expiry != nil {
+		tok.Expiry = *expiry
+	}
+	conn.Token = &tok
+	return &conn, nil
+}
+
+// persistingTokenSource refreshes through base and writes every new token back
+// to calendar_connections, so rotated refresh tokens are never lost.
+type persistingTokenSource struct {
+	app      *App
+	ctx      context.Context
+	userID   string
+	provider string
+	base     oauth2.TokenSource
+
+	mu   sync.Mutex
+	last string // access token most recently persisted
+}
+
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok.AccessToken != s.last {
+		if err := s.app.SaveCalendarConnection(s.ctx, s.userID, s.provider, tok); err != nil {
+			return nil, err
+		}
+		s.last = tok.AccessToken
+	}
+	return tok, nil
+}
+
+// tokenSource returns a token source for a stored connection that refreshes
+// via cfg and persists refreshed tokens.
+func (a *App) tokenSource(ctx context.Context, cfg *oauth2.Config, conn *CalendarConnection) oauth2.TokenSource {
+	return &persistingTokenSource{
+		app:      a,
+		ctx:      ctx,
+		userID:   conn.UserID,
+		provider: conn.Provider,
+		base:     cfg.TokenSource(ctx, conn.Token),
+		last:     conn.Token.AccessToken,
+	}
+}
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// googleProvider is a CalendarProvider backed by the Google Calendar API.
+type googleProvider struct {
+	srv *calendar.Service
+}
+
+func (g *googleProvider) ListCalendars(ctx context.Context) ([]ProviderCalendar, error) {
+	var (
+		out       []ProviderCalendar
+		pageToken string
+	)
+	for {
+		call := g.srv.CalendarList.List().Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		page, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			out = append(out, ProviderCalendar{
+				ID:          item.Id,
+				Name:        item.Summary,
+				Description: item.Description,
+				Primary:     item.Primary,
+				AccessRole:  item.AccessRole,
+			})
+		}
+		if page.NextPageToken == "" {
+			return out, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (g *googleProvider) ListEvents(ctx context.Context, calendarID string, from, to time.Time) ([]ProviderEvent, error) {
+	var (
+		out       []ProviderEvent
+		pageToken string
+	)
+	for {
+		call := g.srv.Events.List(calendarID).
+			SingleEvents(true).
+			OrderBy("startTime").
+			TimeMin(from.UTC().Format(time.RFC3339)).
+			TimeMax(to.UTC().Format(time.RFC3339)).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		page, err := call.Do()
+		if err != nil {
+			return nil, err
+		}
+
+		// all-day events are dates in the calendar's own timezone
+		loc, err := loadTimezone(page.TimeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+		for _, item := range page.Items {
+			span, allDay, err := googleEventSpan(item, loc)
+			if err != nil {
+				continue
+			}
+			ev := ProviderEvent{
+				ID:          item.Id,
+				CalendarID:  calendarID,
+				Summary:     item.Summary,
+				Description: item.Description,
+				Location:    item.Location,
+				Status:      item.Status,
+				Start:       span.Start,
+				End:         span.End,
+				AllDay:      allDay,
+				Transparent: item.Transparency == "transparent",
+				Declined:    googleSelfDeclined(item),
+			}
+			if item.ExtendedProperties != nil {
+				ev.BookingID = item.ExtendedProperties.Private["booking_id"]
+			}
+			out = append(out, ev)
+		}
+
+		if page.NextPageToken == "" {
+			return out, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// FreeBusy derives busy time from events rather than the freebusy API, which
+// can't tell the host's booking-mirrored events apart from other meetings.
+// Calendars the token may only see as free/busy refuse to list events; those
+// fall back to the freebusy API.
+func (g *googleProvider) FreeBusy(ctx context.Context, calendarIDs []string, from, to time.Time) ([]BusyPeriod, error) {
+	var (
+		out          []BusyPeriod
+		freeBusyOnly []string
+	)
+	for _, id := range calendarIDs {
+		events, err := g.ListEvents(ctx, id, from, to)
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusForbidden {
+			freeBusyOnly = append(freeBusyOnly, id)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("events for calendar %s: %w", id, err)
+		}
+		out = append(out, eventBusy(events)...)
+	}
+	if len(freeBusyOnly) == 0 {
+		return out, nil
+	}
+	busy, err := g.queryFreeBusy(ctx, freeBusyOnly, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, busy...), nil
+}
+
+// queryFreeBusy asks the freebusy API for the busy time of calendarIDs.
+func (g *googleProvider) queryFreeBusy(ctx context.Context, calendarIDs []string, from, to time.Time) ([]BusyPeriod, error) {
+	req := &calendar.FreeBusyRequest{
+		TimeMin: from.UTC().Format(time.RFC3339),
+		TimeMax: to.UTC().Format(time.RFC3339),
+	}
+	for _, id := range calendarIDs {
+		req.Items = append(req.Items, &calendar.FreeBusyRequestItem{Id: id})
+	}
+	resp, err := g.srv.Freebusy.Query(req).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []BusyPeriod
+	for id, cal := range resp.Calendars {
+		if len(cal.Errors) > 0 {
+			return nil, fmt.Errorf("free/busy for calendar %s: %s", id, cal.Errors[0].Reason)
+		}
+		for _, p := range cal.Busy {
+			start, err := time.Parse(time.RFC3339, p.Start)
+			if err != nil {
+				return nil, err
+			}
+			end, err := time.Parse(time.RFC3339, p.End)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, BusyPeriod{Start: start, End: end})
+		}
+	}
+	return out, nil
+}
+
+func (g *googleProvider) CreateEvent(ctx context.Context, calendarID string, in EventInput) (string, error) {
+	ev := &calendar.Event{
+		Summary:     in.Summary,
+		Description: in.Description,
+		Location:    in.Location,
+		Start:       &calendar.EventDateTime{DateTime: in.Start.UTC().Format(time.RFC3339)},
+		End:         &calendar.EventDateTime{DateTime: in.End.UTC().Format(time.RFC3339)},
+	}
+	for _, email := range in.Attendees {
+		ev.Attendees = append(ev.Attendees, &calendar.EventAttendee{Email: email})
+	}
+	if in.BookingID != "" {
+		ev.ExtendedProperties = &calendar.EventExtendedProperties{
+			Private: map[string]string{"booking_id": in.BookingID},
+		}
+	}
+	if in.Conference {
+		ev.ConferenceData = &calendar.ConferenceData{
+			CreateRequest: &calendar.CreateConferenceRequest{
+				RequestId:             in.BookingID,
+				ConferenceSolutionKey: &calendar.ConferenceSolutionKey{Type: "hangoutsMeet"},
+			},
+		}
+	}
+
+	call := g.srv.Events.Insert(calendarID, ev).SendUpdates("all").Context(ctx)
+	if in.Conference {
+		call = call.ConferenceDataVersion(1)
+	}
+	created, err := call.Do()
+	if err != nil {
+		return "", err
+	}
+	return created.Id, nil
+}
+
+func (g *googleProvider) UpdateEvent(ctx context.Context, calendarID, eventID string, start, end time.Time) error {
+	patch := &calendar.Event{
+		Start: &calendar.EventDateTime{DateTime: start.UTC().Format(time.RFC3339)},
+		End:   &calendar.EventDateTime{DateTime: end.UTC().Format(time.RFC3339)},
+	}
+	_, err := g.srv.Events.Patch(calendarID, eventID, patch).SendUpdates("all").Context(ctx).Do()
+	return err
+}
+
+func (g *googleProvider) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	return g.srv.Events.Delete(calendarID, eventID).SendUpdates("all").Context(ctx).Do()
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// fakeGoogle serves the Calendar API endpoints googleProvider uses. "primary"
+// lists a meeting, a mirrored booking and a declined invitation; "shared" is
+// only visible as free/busy, so listing its events is forbidden.
+func fakeGoogle(t *testing.T, day time.Time) *googleProvider {
+	at := func(h, m int) string {
+		return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute).Format(time.RFC3339)
+	}
+	mux := http.NewServeMux()
+	reply := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Errorf("encode response: %v", err)
+		}
+	}
+
+	mux.HandleFunc("GET /calendars/primary/events", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusOK, calendar.Events{TimeZone: "UTC", Items: []*calendar.Event{
+			{Id: "meeting", Start: &calendar.EventDateTime{DateTime: at(9, 0)}, End: &calendar.EventDateTime{DateTime: at(10, 0)}},
+			{Id: "mirrored", Start: &calendar.EventDateTime{DateTime: at(11, 0)}, End: &calendar.EventDateTime{DateTime: at(12, 0)},
+				ExtendedProperties: &calendar.EventExtendedProperties{Private: map[string]string{"booking_id": "booking-1"}}},
+			{Id: "declined", Start: &calendar.EventDateTime{DateTime: at(12, 0)}, End: &calendar.EventDateTime{DateTime: at(13, 0)},
+				Attendees: []*calendar.EventAttendee{{Email: "host@example.com", Self: true, ResponseStatus: "declined"}}},
+		}})
+	})
+	mux.HandleFunc("GET /calendars/shared/events", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusForbidden, map[string]any{"error": map[string]any{"code": 403, "message": "Forbidden"}})
+	})
+	mux.HandleFunc("POST /freeBusy", func(w http.ResponseWriter, r *http.Request) {
+		var req calendar.FreeBusyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("freeBusy body: %v", err)
+		}
+		if len(req.Items) != 1 || req.Items[0].Id != "shared" {
+			t.Errorf("freeBusy asked for %+v, want only the shared calendar", req.Items)
+		}
+		reply(w, http.StatusOK, calendar.FreeBusyResponse{Calendars: map[string]calendar.FreeBusyCalendar{
+			"shared": {Busy: []*calendar.TimePeriod{{Start: at(14, 0), End: at(15, 0)}}},
+		}})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	svc, err := calendar.NewService(context.Background(),
+		option.WithHTTPClient(srv.Client()), option.WithEndpoint(srv.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &googleProvider{srv: svc}
+}
+
+func TestGoogleFreeBusyFallsBackForFreeBusyOnlyCalendars(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	g := fakeGoogle(t, day)
+
+	busy, err := g.FreeBusy(context.Background(), []string{"primary", "shared"}, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+	want := []BusyPeriod{
+		{Start: day.Add(9 * time.Hour), End: day.Add(10 * time.Hour)},
+		{Start: day.Add(14 * time.Hour), End: day.Add(15 * time.Hour)},
+	}
+	if len(busy) != len(want) {
+		t.Fatalf("got %+v, want %+v", busy, want)
+	}
+	for i := range want {
+		if !busy[i].Start.Equal(want[i].Start) || !busy[i].End.Equal(want[i].End) {
+			t.Errorf("busy %d = %+v, want %+v", i, busy[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,542 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+)
+
+// defaultGraphBaseURL is the Microsoft Graph endpoint unless MICROSOFT_GRAPH_BASE_URL overrides it.
+const defaultGraphBaseURL = "https://graph.microsoft.com/v1.0"
+
+// graphTimeLayout is how Graph writes dateTimeTimeZone values.
+const graphTimeLayout = "2006-01-02T15:04:05.9999999"
+
+// microsoftWriteEnabled reports whether MICROSOFT_CALENDAR_WRITE opts into
+// writing bookings to Outlook calendars. Like GOOGLE_CALENDAR_WRITE it widens
+// the OAuth scope, so hosts connected earlier must reconnect.
+func microsoftWriteEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("MICROSOFT_CALENDAR_WRITE"))
+	return enabled
+}
+
+// graphBaseURL returns the Graph API root without a trailing slash.
+func graphBaseURL() string {
+	if base := strings.TrimSpace(os.Getenv("MICROSOFT_GRAPH_BASE_URL")); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return defaultGraphBaseURL
+}
+
+// InitMicrosoftCalendarConfig initializes OAuth2 config for Microsoft identity
+// platform. MICROSOFT_TENANT defaults to "common" (work and personal accounts).
+func InitMicrosoftCalendarConfig() *oauth2.Config {
+	clientID := os.Getenv("MICROSOFT_CLIENT_ID")
+	clientSecret := os.Getenv("MICROSOFT_CLIENT_SECRET")
+	redirectURL := os.Getenv("MICROSOFT_REDIRECT_URL")
+	if clientID == "" || clientSecret == "" || redirectURL == "" {
+		return nil
+	}
+
+	tenant := os.Getenv("MICROSOFT_TENANT")
+	if tenant == "" {
+		tenant = "common"
+	}
+	scope := "Calendars.Read"
+	if microsoftWriteEnabled() {
+		scope = "Calendars.ReadWrite"
+	}
+
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"offline_access", "User.Read", scope},
+		Endpoint:     microsoft.AzureADEndpoint(tenant),
+	}
+}
+
+// MicrosoftAuthHandler initiates the Microsoft OAuth2 flow
+func (a *App) MicrosoftAuthHandler(c *gin.Context) {
+	cfg := InitMicrosoftCalendarConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Microsoft Calendar not configured"})
+		return
+	}
+
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
+		return
+	}
+
+	state, err := a.createOAuthState(c.Request.Context(), userID, providerMicrosoft)
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start authorization"})
+		return
+	}
+
+	url, err := oauthStartURL(cfg.RedirectURL, state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid redirect URL"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"auth_url":   url,
+		"state":      state,
+		"expires_in": int(oauthStateTTL.Seconds()),
+	})
+}
+
+// MicrosoftOAuth2CallbackHandler handles the Microsoft OAuth2 callback
+func (a *App) MicrosoftOAuth2CallbackHandler(c *gin.Context) {
+	cfg := InitMicrosoftCalendarConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Microsoft Calendar not configured"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	pending, err := a.consumeOAuthState(ctx, c.Query("state"), takeOAuthBrowserCookie(c), providerMicrosoft)
+	if errors.Is(err, errInvalidOAuthState) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify state"})
+		return
+	}
+
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorization denied: " + reason})
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "authorization code required"})
+		return
+	}
+
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(pending.Verifier))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to exchange code for token"})
+		return
+	}
+	// authorized by the state rather than a tenant, like the Google callback
+	if err := a.SaveCalendarConnection(asSystem(ctx), pending.UserID, providerMicrosoft, token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store calendar connection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Authorization successful",
+		"user_id":  pending.UserID,
+		"provider": providerMicrosoft,
+	})
+}
+
+// microsoftProvider opens userID's stored Microsoft connection.
+func (a *App) microsoftProvider(ctx context.Context, userID string) (CalendarProvider, error) {
+	cfg := InitMicrosoftCalendarConfig()
+	if cfg == nil {
+		return nil, errProviderNotConfigured
+	}
+	conn, err := a.GetCalendarConnection(ctx, userID, providerMicrosoft)
+	if err != nil {
+		return nil, err
+	}
+	client := oauth2.NewClient(ctx, a.tokenSource(ctx, cfg, conn))
+	client.Timeout = providerHTTPTimeout
+	return &graphClient{http: client, base: graphBaseURL()}, nil
+}
+
+// graphClient is a CalendarProvider backed by Microsoft Graph. defaultCalendarID
+// maps to the mailbox's default calendar.
+type graphClient struct {
+	http *http.Client
+	base string
+}
+
+// graphError is the error body Graph returns.
+type graphError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// do sends a Graph request. target is a path under the base URL or an
+// @odata.nextLink, which must point back at the base URL.
+func (g *graphClient) do(ctx context.Context, method, target string, header http.Header, in, out any, want ...int) error {
+	if !strings.HasPrefix(target, g.base+"/") {
+		if strings.Contains(target, "://") {
+			return fmt.Errorf("graph: unexpected link %q", target)
+		}
+		target = g.base + target
+	}
+
+	var body io.Reader
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, code := range want {
+		if resp.StatusCode == code {
+			if out == nil {
+				return nil
+			}
+			return json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(out)
+		}
+	}
+	var gerr graphError
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&gerr)
+	if gerr.Error.Code != "" {
+		return fmt.Errorf("graph: %s %s: %s: %s", method, req.URL.Path, gerr.Error.Code, gerr.Error.Message)
+	}
+	return fmt.Errorf("graph: %s %s: %s", method, req.URL.Path, resp.Status)
+}
+
+// calendarPath is the Graph path of a calendar; the primary alias is the default calendar.
+func (g *graphClient) calendarPath(calendarID string) string {
+	if calendarID == defaultCalendarID {
+		return "/me/calendar"
+	}
+	return "/me/calendars/" + url.PathEscape(calendarID)
+}
+
+// graphDateTime is Graph's dateTimeTimeZone.
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+func newGraphDateTime(t time.Time) graphDateTime {
+	return graphDateTime{DateTime: t.UTC().Format(graphTimeLayout), TimeZone: "UTC"}
+}
+
+func (d graphDateTime) time() (time.Time, error) {
+	loc, err := loadTimezone(d.TimeZone)
+	if err != nil {
+		// Windows zone names aren't in the tz database; requests ask for UTC anyway
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(graphTimeLayout, d.DateTime, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// preferUTC asks Graph to report event times in UTC.
+var preferUTC = http.Header{"Prefer": {`outlook.timezone="UTC"`}}
+
+func (g *graphClient) ListCalendars(ctx context.Context) ([]ProviderCalendar, error) {
+	var out []ProviderCalendar
+	next := "/me/calendars?$top=100"
+	for next != "" {
+		var page struct {
+			Value []struct {
+				ID                string `json:"id"`
+				Name              string `json:"name"`
+				IsDefaultCalendar bool   `json:"isDefaultCalendar"`
+				CanEdit           bool   `json:"canEdit"`
+				Owner             struct {
+					Address string `json:"address"`
+				} `json:"owner"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := g.do(ctx, http.MethodGet, next, nil, nil, &page, http.StatusOK); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Value {
+			pc := ProviderCalendar{
+				ID:         item.ID,
+				Name:       item.Name,
+				Primary:    item.IsDefaultCalendar,
+				AccessRole: "reader",
+			}
+			if item.CanEdit {
+				pc.AccessRole = "writer"
+			}
+			if item.IsDefaultCalendar {
+				pc.AccessRole = "owner"
+			}
+			out = append(out, pc)
+		}
+		next = page.NextLink
+	}
+	return out, nil
+}
+
+// graphEvent is the subset of a Graph event the scheduler reads.
+type graphEvent struct {
+	ID          string        `json:"id"`
+	Subject     string        `json:"subject"`
+	BodyPreview string        `json:"bodyPreview"`
+	Start       graphDateTime `json:"start"`
+	End         graphDateTime `json:"end"`
+	IsAllDay    bool          `json:"isAllDay"`
+	IsCancelled bool          `json:"isCancelled"`
+	ShowAs      string        `json:"showAs"`
+	Location    struct {
+		DisplayName string `json:"displayName"`
+	} `json:"location"`
+	ResponseStatus struct {
+		Response string `json:"response"`
+	} `json:"responseStatus"`
+	TransactionID string `json:"transactionId"`
+}
+
+func (g *graphClient) ListEvents(ctx context.Context, calendarID string, from, to time.Time) ([]ProviderEvent, error) {
+	q := url.Values{
+		"startDateTime": {from.UTC().Format(time.RFC3339)},
+		"endDateTime":   {to.UTC().Format(time.RFC3339)},
+		"$top":          {"100"},
+		"$orderby":      {"start/dateTime"},
+	}
+	next := g.calendarPath(calendarID) + "/calendarView?" + q.Encode()
+
+	var out []ProviderEvent
+	for next != "" {
+		var page struct {
+			Value    []graphEvent `json:"value"`
+			NextLink string       `json:"@odata.nextLink"`
+		}
+		if err := g.do(ctx, http.MethodGet, next, preferUTC, nil, &page, http.StatusOK); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Value {
+			start, err := item.Start.time()
+			if err != nil {
+				continue
+			}
+			end, err := item.End.time()
+			if err != nil {
+				continue
+			}
+			status := "confirmed"
+			if item.IsCancelled {
+				status = "cancelled"
+			}
+			out = append(out, ProviderEvent{
+				ID:          item.ID,
+				CalendarID:  calendarID,
+				Summary:     item.Subject,
+				Description: item.BodyPreview,
+				Location:    item.Location.DisplayName,
+				Status:      status,
+				Start:       start,
+				End:         end,
+				AllDay:      item.IsAllDay,
+				Transparent: !graphShowAsBusy(item.ShowAs),
+				Declined:    item.ResponseStatus.Response == "declined",
+				BookingID:   item.TransactionID,
+			})
+		}
+		next = page.NextLink
+	}
+	return out, nil
+}
+
+// graphShowAsBusy reports whether a showAs/status value blocks time.
+func graphShowAsBusy(showAs string) bool {
+	switch showAs {
+	case "busy", "oof", "tentative":
+		return true
+	}
+	return false
+}
+
+// FreeBusy asks getSchedule for the mailbox's default calendar and reads the
+// calendar view of any other selected calendar, since getSchedule only covers
+// whole mailboxes.
+func (g *graphClient) FreeBusy(ctx context.Context, calendarIDs []string, from, to time.Time) ([]BusyPeriod, error) {
+	var (
+		out       []BusyPeriod
+		defaultID string
+		looked    bool
+	)
+	for _, id := range calendarIDs {
+		if id != defaultCalendarID {
+			if !looked {
+				calendars, err := g.ListCalendars(ctx)
+				if err != nil {
+					return nil, err
+				}
+				for _, pc := range calendars {
+					if pc.Primary {
+						defaultID = pc.ID
+					}
+				}
+				looked = true
+			}
+			if id != defaultID {
+				events, err := g.ListEvents(ctx, id, from, to)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, eventBusy(events)...)
+				continue
+			}
+		}
+
+		periods, err := g.schedule(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, periods...)
+	}
+	return out, nil
+}
+
+// schedule returns the signed-in mailbox's busy periods from getSchedule.
+func (g *graphClient) schedule(ctx context.Context, from, to time.Time) ([]BusyPeriod, error) {
+	var me struct {
+		Mail              string `json:"mail"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}
+	if err := g.do(ctx, http.MethodGet, "/me?$select=mail,userPrincipalName", nil, nil, &me, http.StatusOK); err != nil {
+		return nil, err
+	}
+	mailbox := me.Mail
+	if mailbox == "" {
+		mailbox = me.UserPrincipalName
+	}
+
+	req := map[string]any{
+		"schedules": []string{mailbox},
+		"startTime": newGraphDateTime(from),
+		"endTime":   newGraphDateTime(to),
+	}
+	var resp struct {
+		Value []struct {
+			ScheduleID    string `json:"scheduleId"`
+			ScheduleItems []struct {
+				Status string        `json:"status"`
+				Start  graphDateTime `json:"start"`
+				End    graphDateTime `json:"end"`
+			} `json:"scheduleItems"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"value"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/me/calendar/getSchedule", preferUTC, req, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var out []BusyPeriod
+	for _, sched := range resp.Value {
+		if sched.Error != nil {
+			return nil, fmt.Errorf("graph: schedule for %s: %s", sched.ScheduleID, sched.Error.Message)
+		}
+		for _, item := range sched.ScheduleItems {
+			if !graphShowAsBusy(item.Status) {
+				continue
+			}
+			start, err := item.Start.time()
+			if err != nil {
+				return nil, err
+			}
+			end, err := item.End.time()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, BusyPeriod{Start: start, End: end})
+		}
+	}
+	return out, nil
+}
+
+func (g *graphClient) CreateEvent(ctx context.Context, calendarID string, in EventInput) (string, error) {
+	type emailAddress struct {
+		Address string `json:"address"`
+	}
+	type attendee struct {
+		EmailAddress emailAddress `json:"emailAddress"`
+		Type         string       `json:"type"`
+	}
+	body := map[string]any{
+		"subject": in.Summary,
+		"body":    map[string]string{"contentType": "text", "content": in.Description},
+		"start":   newGraphDateTime(in.Start),
+		"end":     newGraphDateTime(in.End),
+		"showAs":  "busy",
+	}
+	if in.Location != "" {
+		body["location"] = map[string]string{"displayName": in.Location}
+	}
+	attendees := make([]attendee, 0, len(in.Attendees))
+	for _, email := range in.Attendees {
+		attendees = append(attendees, attendee{EmailAddress: emailAddress{Address: email}, Type: "required"})
+	}
+	body["attendees"] = attendees
+	if in.BookingID != "" {
+		// also makes a retried create return the existing event
+		body["transactionId"] = in.BookingID
+	}
+	if in.Conference {
+		body["isOnlineMeeting"] = true
+		body["onlineMeetingProvider"] = "teamsForBusiness"
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := g.do(ctx, http.MethodPost, g.calendarPath(calendarID)+"/events", nil, body, &created,
+		http.StatusCreated, http.StatusOK); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+func (g *graphClient) UpdateEvent(ctx context.Context, calendarID, eventID string, start, end time.Time) error {
+	patch := map[string]any{
+		"start": newGraphDateTime(start),
+		"end":   newGraphDateTime(end),
+	}
+	return g.do(ctx, http.MethodPatch, "/me/events/"+url.PathEscape(eventID), nil, patch, nil, http.StatusOK)
+}
+
+func (g *graphClient) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	return g.do(ctx, http.MethodDelete, "/me/events/"+url.PathEscape(eventID), nil, nil, nil,
+		http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+}
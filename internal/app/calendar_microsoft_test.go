@@ -0,0 +1,281 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGraph serves the Graph endpoints graphClient uses. Calendars and events
+// come back in two pages linked by @odata.nextLink.
+type fakeGraph struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	created map[string]graphEvent // by transactionId
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	f := &fakeGraph{t: t, created: make(map[string]graphEvent)}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1.0/me/calendars", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("$skiptoken") == "" {
+			f.json(w, http.StatusOK, map[string]any{
+				"value": []map[string]any{
+					{"id": "cal-default", "name": "Calendar", "isDefaultCalendar": true, "canEdit": true},
+					{"id": "cal-team", "name": "Team", "canEdit": true},
+				},
+				"@odata.nextLink": f.srv.URL + "/v1.0/me/calendars?$skiptoken=2",
+			})
+			return
+		}
+		f.json(w, http.StatusOK, map[string]any{
+			"value": []map[string]any{{"id": "cal-holidays", "name": "Holidays"}},
+		})
+	})
+
+	mux.HandleFunc("GET /v1.0/me/calendars/cal-team/calendarView", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Prefer"); got != `outlook.timezone="UTC"` {
+			t.Errorf("calendarView Prefer = %q", got)
+		}
+		if r.URL.Query().Get("$skiptoken") == "" {
+			if r.URL.Query().Get("startDateTime") == "" || r.URL.Query().Get("endDateTime") == "" {
+				t.Errorf("calendarView without a window: %s", r.URL.RawQuery)
+			}
+			f.json(w, http.StatusOK, map[string]any{
+				"value": []graphEvent{
+					{ID: "ev-1", Subject: "Standup", ShowAs: "busy",
+						Start: graphDateTime{DateTime: "2026-03-02T09:00:00.0000000", TimeZone: "UTC"},
+						End:   graphDateTime{DateTime: "2026-03-02T09:30:00.0000000", TimeZone: "UTC"}},
+					{ID: "ev-2", Subject: "Focus", ShowAs: "free",
+						Start: graphDateTime{DateTime: "2026-03-02T10:00:00.0000000", TimeZone: "UTC"},
+						End:   graphDateTime{DateTime: "2026-03-02T11:00:00.0000000", TimeZone: "UTC"}},
+				},
+				"@odata.nextLink": f.srv.URL + "/v1.0/me/calendars/cal-team/calendarView?$skiptoken=2",
+			})
+			return
+		}
+		events := []graphEvent{
+			{ID: "ev-3", Subject: "Cancelled", ShowAs: "busy", IsCancelled: true,
+				Start: graphDateTime{DateTime: "2026-03-02T12:00:00.0000000", TimeZone: "UTC"},
+				End:   graphDateTime{DateTime: "2026-03-02T13:00:00.0000000", TimeZone: "UTC"}},
+			{ID: "ev-4", Subject: "Declined", ShowAs: "busy",
+				Start: graphDateTime{DateTime: "2026-03-02T13:00:00.0000000", TimeZone: "UTC"},
+				End:   graphDateTime{DateTime: "2026-03-02T14:00:00.0000000", TimeZone: "UTC"}},
+		}
+		events[1].ResponseStatus.Response = "declined"
+		f.mu.Lock()
+		for _, ev := range f.created {
+			events = append(events, ev)
+		}
+		f.mu.Unlock()
+		f.json(w, http.StatusOK, map[string]any{"value": events})
+	})
+
+	mux.HandleFunc("GET /v1.0/me", func(w http.ResponseWriter, r *http.Request) {
+		f.json(w, http.StatusOK, map[string]string{"userPrincipalName": "host@example.com"})
+	})
+
+	mux.HandleFunc("POST /v1.0/me/calendar/getSchedule", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Schedules []string      `json:"schedules"`
+			StartTime graphDateTime `json:"startTime"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("getSchedule body: %v", err)
+		}
+		if len(req.Schedules) != 1 || req.Schedules[0] != "host@example.com" {
+			t.Errorf("getSchedule schedules = %v", req.Schedules)
+		}
+		if req.StartTime.TimeZone != "UTC" {
+			t.Errorf("getSchedule startTime zone = %q", req.StartTime.TimeZone)
+		}
+		f.json(w, http.StatusOK, map[string]any{
+			"value": []map[string]any{{
+				"scheduleId": "host@example.com",
+				"scheduleItems": []map[string]any{
+					{"status": "busy",
+						"start": graphDateTime{DateTime: "2026-03-02T14:00:00.0000000", TimeZone: "UTC"},
+						"end":   graphDateTime{DateTime: "2026-03-02T15:00:00.0000000", TimeZone: "UTC"}},
+					{"status": "free",
+						"start": graphDateTime{DateTime: "2026-03-02T15:00:00.0000000", TimeZone: "UTC"},
+						"end":   graphDateTime{DateTime: "2026-03-02T16:00:00.0000000", TimeZone: "UTC"}},
+				},
+			}},
+		})
+	})
+
+	// Graph answers a create whose transactionId it has seen with the existing event.
+	mux.HandleFunc("POST /v1.0/me/calendars/cal-team/events", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Subject       string        `json:"subject"`
+			Start         graphDateTime `json:"start"`
+			End           graphDateTime `json:"end"`
+			ShowAs        string        `json:"showAs"`
+			TransactionID string        `json:"transactionId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("create body: %v", err)
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if ev, ok := f.created[body.TransactionID]; ok {
+			f.json(w, http.StatusOK, map[string]string{"id": ev.ID})
+			return
+		}
+		ev := graphEvent{ID: "created-1", Subject: body.Subject, Start: body.Start, End: body.End,
+			ShowAs: body.ShowAs, TransactionID: body.TransactionID}
+		f.created[body.TransactionID] = ev
+		f.json(w, http.StatusCreated, map[string]string{"id": ev.ID})
+	})
+
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeGraph) json(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		f.t.Errorf("encode response: %v", err)
+	}
+}
+
+func (f *fakeGraph) client() *graphClient {
+	return &graphClient{http: f.srv.Client(), base: f.srv.URL + "/v1.0"}
+}
+
+func TestGraphListCalendars(t *testing.T) {
+	g := newFakeGraph(t).client()
+
+	calendars, err := g.ListCalendars(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProviderCalendar{
+		{ID: "cal-default", Name: "Calendar", Primary: true, AccessRole: "owner"},
+		{ID: "cal-team", Name: "Team", AccessRole: "writer"},
+		{ID: "cal-holidays", Name: "Holidays", AccessRole: "reader"},
+	}
+	if len(calendars) != len(want) {
+		t.Fatalf("got %d calendars, want %d: %+v", len(calendars), len(want), calendars)
+	}
+	for i := range want {
+		if calendars[i] != want[i] {
+			t.Errorf("calendar %d = %+v, want %+v", i, calendars[i], want[i])
+		}
+	}
+}
+
+func TestGraphListEventsFollowsNextLink(t *testing.T) {
+	g := newFakeGraph(t).client()
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	events, err := g.ListEvents(context.Background(), "cal-team", from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4 across both pages: %+v", len(events), events)
+	}
+
+	standup := events[0]
+	if standup.ID != "ev-1" || standup.CalendarID != "cal-team" || standup.Transparent || standup.Status != "confirmed" {
+		t.Errorf("standup = %+v", standup)
+	}
+	if !standup.Start.Equal(from.Add(9*time.Hour)) || !standup.End.Equal(from.Add(9*time.Hour+30*time.Minute)) {
+		t.Errorf("standup span = %v - %v", standup.Start, standup.End)
+	}
+	if !events[1].Transparent {
+		t.Errorf("free event not transparent: %+v", events[1])
+	}
+	if events[2].Status != "cancelled" {
+		t.Errorf("cancelled event status = %q", events[2].Status)
+	}
+	if !events[3].Declined || events[0].Declined {
+		t.Errorf("declined = %v, %v; want only the declined invitation", events[3].Declined, events[0].Declined)
+	}
+}
+
+func TestGraphFreeBusy(t *testing.T) {
+	g := newFakeGraph(t).client()
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	// the default calendar goes through getSchedule, by alias or by ID; the
+	// team calendar through its calendar view
+	for _, ids := range [][]string{{defaultCalendarID, "cal-team"}, {"cal-default", "cal-team"}} {
+		busy, err := g.FreeBusy(context.Background(), ids, from, from.Add(24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []BusyPeriod{
+			{Start: from.Add(14 * time.Hour), End: from.Add(15 * time.Hour)},
+			{Start: from.Add(9 * time.Hour), End: from.Add(9*time.Hour + 30*time.Minute)},
+		}
+		if len(busy) != len(want) {
+			t.Fatalf("%v: got %+v, want %+v", ids, busy, want)
+		}
+		for i := range want {
+			if !busy[i].Start.Equal(want[i].Start) || !busy[i].End.Equal(want[i].End) {
+				t.Errorf("%v: busy %d = %+v, want %+v", ids, i, busy[i], want[i])
+			}
+		}
+	}
+}
+
+func TestGraphCreateEventTransactionID(t *testing.T) {
+	g := newFakeGraph(t).client()
+	ctx := context.Background()
+	start := time.Date(2026, 3, 2, 16, 0, 0, 0, time.UTC)
+	in := EventInput{Summary: "Intro call", Start: start, End: start.Add(30 * time.Minute), BookingID: "booking-42"}
+
+	id, err := g.CreateEvent(ctx, "cal-team", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retried, err := g.CreateEvent(ctx, "cal-team", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried != id {
+		t.Errorf("retried create returned %q, want existing event %q", retried, id)
+	}
+
+	events, err := g.ListEvents(ctx, "cal-team", start.Add(-time.Hour), start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mirrored *ProviderEvent
+	for i := range events {
+		if events[i].ID == id {
+			mirrored = &events[i]
+		}
+	}
+	if mirrored == nil {
+		t.Fatalf("created event %q not listed: %+v", id, events)
+	}
+	if mirrored.BookingID != "booking-42" {
+		t.Errorf("BookingID = %q, want booking-42", mirrored.BookingID)
+	}
+	if !mirrored.Start.Equal(start) || !mirrored.End.Equal(in.End) {
+		t.Errorf("mirrored span = %v - %v", mirrored.Start, mirrored.End)
+	}
+
+	// mirrored bookings don't make the host busy
+	busy, err := g.FreeBusy(ctx, []string{"cal-team"}, start.Add(-time.Hour), start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range busy {
+		if p.Start.Equal(start) {
+			t.Errorf("mirrored booking counted as busy: %+v", p)
+		}
+	}
+}
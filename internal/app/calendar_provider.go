@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// providerHTTPTimeout bounds each request to a calendar provider's API.
+const providerHTTPTimeout = 30 * time.Second
+
+// errProviderNotConfigured is returned for a provider the server has no credentials for.
+var errProviderNotConfigured = errors.New("calendar provider not configured")
+
+// ProviderCalendar is one calendar in a connected account.
+type ProviderCalendar struct {
+	ID          string
+	Name        string
+	Description string
+	Primary     bool
+	AccessRole  string // "owner", "writer" or "reader"
+}
+
+// writable reports whether events can be created in the calendar.
+func (pc ProviderCalendar) writable() bool {
+	return pc.AccessRole == "owner" || pc.AccessRole == "writer"
+}
+
+// ProviderEvent is an event read from a provider, with times in UTC.
+type ProviderEvent struct {
+	ID          string
+	CalendarID  string
+	Summary     string
+	Description string
+	Location    string
+	Status      string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Transparent bool   // shown as free
+	Declined    bool   // an invitation the calendar's owner declined
+	BookingID   string // set on events mirrored from a booking
+}
+
+// eventBusy returns the busy periods of events. Free, cancelled, declined and
+// booking-mirrored events don't count; bookings are checked on their own.
+func eventBusy(events []ProviderEvent) []BusyPeriod {
+	var out []BusyPeriod
+	for _, ev := range events {
+		if ev.Transparent || ev.Declined || ev.Status == "cancelled" || ev.BookingID != "" || !ev.End.After(ev.Start) {
+			continue
+		}
+		out = append(out, BusyPeriod{Start: ev.Start, End: ev.End})
+	}
+	return out
+}
+
+// EventInput is an event to create on a provider.
+type EventInput struct {
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Attendees   []string
+	BookingID   string // lets the event be matched back to its booking
+	Conference  bool   // request a video conference where the provider supports it
+}
+
+// CalendarProvider is one user's connection to a calendar backend.
+// Calendar IDs are provider-specific; defaultCalendarID names the account's
+// main calendar on every provider.
+type CalendarProvider interface {
+	ListCalendars(ctx context.Context) ([]ProviderCalendar, error)
+	ListEvents(ctx context.Context, calendarID string, from, to time.Time) ([]ProviderEvent, error)
+	FreeBusy(ctx context.Context, calendarIDs []string, from, to time.Time) ([]BusyPeriod, error)
+	CreateEvent(ctx context.Context, calendarID string, ev EventInput) (eventID string, err error)
+	// UpdateEvent moves an existing event to start/end.
+	UpdateEvent(ctx context.Context, calendarID, eventID string, start, end time.Time) error
+	DeleteEvent(ctx context.Context, calendarID, eventID string) error
+}
+
+// knownProvider reports whether name is a supported calendar provider.
+func knownProvider(name string) bool {
+	switch name {
+	case providerGoogle, providerMicrosoft, providerCalDAV:
+		return true
+	}
+	return false
+}
+
+// calendarProvider opens userID's connection to provider. It returns
+// errNoCalendarConnection when the user has not connected it.
+func (a *App) calendarProvider(ctx context.Context, userID, provider string) (CalendarProvider, error) {
+	switch provider {
+	case providerGoogle:
+		cfg := InitGoogleCalendarConfig()
+		if cfg == nil {
+			return nil, errProviderNotConfigured
+		}
+		srv, err := a.googleCalendarService(ctx, cfg, userID)
+		if err != nil {
+			return nil, err
+		}
+		return &googleProvider{srv: srv}, nil
+	case providerMicrosoft:
+		return a.microsoftProvider(ctx, userID)
+	case providerCalDAV:
+		return a.caldavProvider(ctx, userID)
+	}
+	return nil, fmt.Errorf("unknown calendar provider: %s", provider)
+}
+
+// connectedProviders lists the providers userID has connected, Google first.
+func (a *App) connectedProviders(ctx context.Context, userID string) ([]string, error) {
+	rows, err := a.db(ctx).Query(ctx, `SELECT provider FROM calendar_connections WHERE user_id=$1
+	                              ORDER BY provider <> $2, created_at`, userID, providerGoogle)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// writeTarget returns the calendar that receives userID's new bookings: the
+// selected target, or else the main calendar of their first connected provider.
+func (a *App) writeTarget(ctx context.Context, userID string) (provider, calendarID string, err error) {
+	err = a.db(ctx).QueryRow(ctx, `SELECT provider, calendar_id FROM calendar_selections
+	                          WHERE user_id=$1 AND target`, userID).Scan(&provider, &calendarID)
+	if err == nil {
+		return provider, calendarID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", err
+	}
+
+	// no target chosen, but an explicit selection on a provider opts out of its default
+	providers, err := a.connectedProviders(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	for _, p := range providers {
+		var selected bool
+		if err := a.db(ctx).QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM calendar_selections
+		                              WHERE user_id=$1 AND provider=$2)`, userID, p).Scan(&selected); err != nil {
+			return "", "", err
+		}
+		if !selected {
+			return p, defaultCalendarID, nil
+		}
+	}
+	return "", "", errNoCalendarConnection
+}
@@ -0,0 +1,228 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultCalendarID is the provider alias for a user's main calendar. It is used
+// for both conflicts and new events until the user saves a selection.
+const defaultCalendarID = "primary"
+
+// emptySelectionID marks a saved selection with no calendars, so that "none"
+// is kept apart from "never configured".
+const emptySelectionID = ""
+
+// CalendarSelection is which of a user's calendars count as busy time and which
+// one receives events for new bookings.
+type CalendarSelection struct {
+	ConflictCalendarIDs []string `json:"conflict_calendar_ids"`
+	TargetCalendarID    string   `json:"target_calendar_id"`
+}
+
+// GetCalendarSelection loads a user's selection for provider. Without a saved
+// selection the main calendar counts as busy. TargetCalendarID is empty when
+// new events go to another provider.
+func (a *App) GetCalendarSelection(ctx context.Context, userID, provider string) (*CalendarSelection, error) {
+	q := `SELECT calendar_id, conflict FROM calendar_selections
+	      WHERE user_id=$1 AND provider=$2 ORDER BY calendar_id`
+	rows, err := a.db(ctx).Query(ctx, q, userID, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		sel   = CalendarSelection{ConflictCalendarIDs: []string{}}
+		found bool
+	)
+	for rows.Next() {
+		var (
+			id       string
+			conflict bool
+		)
+		if err := rows.Scan(&id, &conflict); err != nil {
+			return nil, err
+		}
+		found = true
+		if conflict && id != emptySelectionID {
+			sel.ConflictCalendarIDs = append(sel.ConflictCalendarIDs, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		sel.ConflictCalendarIDs = []string{defaultCalendarID}
+	}
+
+	targetProvider, targetID, err := a.writeTarget(ctx, userID)
+	if err != nil && !errors.Is(err, errNoCalendarConnection) {
+		return nil, err
+	}
+	if err == nil && targetProvider == provider {
+		sel.TargetCalendarID = targetID
+	}
+	return &sel, nil
+}
+
+// SaveCalendarSelection replaces a user's selection for provider. Choosing a
+// target here moves it away from any other provider. An empty selection is
+// kept as such: no calendar blocks time and none receives events.
+func (a *App) SaveCalendarSelection(ctx context.Context, userID, provider string, sel CalendarSelection) error {
+	tx, err := a.db(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM calendar_selections WHERE user_id=$1 AND provider=$2`,
+		userID, provider); err != nil {
+		return err
+	}
+	if sel.TargetCalendarID != "" {
+		if _, err := tx.Exec(ctx, `UPDATE calendar_selections SET target=false, updated_at=now()
+		                           WHERE user_id=$1 AND target`, userID); err != nil {
+			return err
+		}
+	}
+
+	ins := `INSERT INTO calendar_selections (user_id, provider, calendar_id, conflict, target, updated_at)
+            VALUES ($1, $2, $3, $4, $5, now())`
+	targetSaved := sel.TargetCalendarID == ""
+	for _, id := range sel.ConflictCalendarIDs {
+		isTarget := id == sel.TargetCalendarID
+		targetSaved = targetSaved || isTarget
+		if _, err := tx.Exec(ctx, ins, userID, provider, id, true, isTarget); err != nil {
+			return err
+		}
+	}
+	if !targetSaved {
+		if _, err := tx.Exec(ctx, ins, userID, provider, sel.TargetCalendarID, false, true); err != nil {
+			return err
+		}
+	}
+	if len(sel.ConflictCalendarIDs) == 0 && sel.TargetCalendarID == "" {
+		if _, err := tx.Exec(ctx, ins, userID, provider, emptySelectionID, false, false); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// selectionIncludes reports whether id, or the primary alias of the calendar
+// it names, is in ids.
+func selectionIncludes(ids []string, id string, primary bool) bool {
+	for _, s := range ids {
+		if s == id || (primary && s == defaultCalendarID) {
+			return true
+		}
+	}
+	return false
+}
+
+// providerFromQuery reads the provider query parameter, defaulting to Google.
+func providerFromQuery(c *gin.Context) (string, bool) {
+	provider := c.DefaultQuery("provider", providerGoogle)
+	if !knownProvider(provider) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider: " + provider})
+		return "", false
+	}
+	return provider, true
+}
+
+// UpdateCalendarSelectionHandler saves which of a provider's calendars block
+// time and, optionally, which one receives new events. Calendars must be in
+// the user's calendar list, and the target must be writable.
+// PUT /api/calendar/calendars
+func (a *App) UpdateCalendarSelectionHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
+		return
+	}
+	provider, ok := providerFromQuery(c)
+	if !ok {
+		return
+	}
+
+	var sel CalendarSelection
+	if err := c.ShouldBindJSON(&sel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	p, err := a.calendarProvider(ctx, userID, provider)
+	if err != nil {
+		respondCalendarServiceError(c, err)
+		return
+	}
+	calendars, err := p.ListCalendars(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to retrieve calendars"})
+		return
+	}
+
+	// resolve IDs against the calendar list, mapping the primary alias to the real ID
+	byID := make(map[string]ProviderCalendar, len(calendars))
+	primaryID := ""
+	for _, pc := range calendars {
+		byID[pc.ID] = pc
+		if pc.Primary {
+			primaryID = pc.ID
+		}
+	}
+	resolve := func(id string) (string, bool) {
+		if id == defaultCalendarID && primaryID != "" {
+			return primaryID, true
+		}
+		_, ok := byID[id]
+		return id, ok
+	}
+
+	seen := make(map[string]bool)
+	conflicts := make([]string, 0, len(sel.ConflictCalendarIDs))
+	for _, id := range sel.ConflictCalendarIDs {
+		resolved, ok := resolve(id)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown calendar: " + id})
+			return
+		}
+		if !seen[resolved] {
+			seen[resolved] = true
+			conflicts = append(conflicts, resolved)
+		}
+	}
+	target := ""
+	if sel.TargetCalendarID != "" {
+		if target, ok = resolve(sel.TargetCalendarID); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown calendar: " + sel.TargetCalendarID})
+			return
+		}
+		if !byID[target].writable() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target calendar is not writable"})
+			return
+		}
+	}
+	sel = CalendarSelection{ConflictCalendarIDs: conflicts, TargetCalendarID: target}
+
+	if err := a.SaveCalendarSelection(ctx, userID, provider, sel); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// keep Google push sync, if enabled, on the newly selected calendars
+	if provider == providerGoogle {
+		gp := p.(*googleProvider)
+		if err := a.rewatchSelectedCalendars(ctx, gp.srv, userID, sel.ConflictCalendarIDs); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "selection saved, but failed to update calendar sync"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, sel)
+}
@@ -0,0 +1,755 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// watchChannelTTL is the lifetime requested for a watch channel; Google may shorten it.
+	watchChannelTTL = 7 * 24 * time.Hour
+	// watchRenewBefore is how long before expiry a channel is replaced.
+	watchRenewBefore = 24 * time.Hour
+	// syncLookback bounds a full sync; older events never affect bookable slots.
+	syncLookback = 24 * time.Hour
+	// webhookSyncTimeout bounds a sync triggered by a push notification.
+	webhookSyncTimeout = time.Minute
+	// maxSyncAttempts bounds how often a sync restarts because another one
+	// advanced the calendar's sync token first.
+	maxSyncAttempts = 3
+)
+
+// errPushSyncNotConfigured is returned when GOOGLE_WEBHOOK_URL is unset.
+var errPushSyncNotConfigured = errors.New("calendar push sync not configured")
+
+// googleWebhookURL is the public HTTPS address Google delivers notifications to.
+func googleWebhookURL() string {
+	return strings.TrimSpace(os.Getenv("GOOGLE_WEBHOOK_URL"))
+}
+
+// watchChannel is a registered Google Calendar push channel.
+type watchChannel struct {
+	ID         string
+	UserID     string
+	Provider   string
+	CalendarID string
+	ResourceID string
+	TokenHash  []byte
+	ExpiresAt  time.Time
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashChannelToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func (a *App) getWatchChannel(ctx context.Context, id string) (*watchChannel, error) {
+	q := `SELECT id, user_id::text, provider, calendar_id, resource_id, token_hash, expires_at
+	      FROM calendar_watch_channels WHERE id=$1`
+	var ch watchChannel
+	err := a.db(ctx).QueryRow(ctx, q, id).Scan(&ch.ID, &ch.UserID, &ch.Provider, &ch.CalendarID,
+		&ch.ResourceID, &ch.TokenHash, &ch.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func (a *App) listWatchChannels(ctx context.Context, where string, args ...any) ([]watchChannel, error) {
+	q := `SELECT id, user_id::text, provider, calendar_id, resource_id, token_hash, expires_at
+	      FROM calendar_watch_channels WHERE ` + where + ` ORDER BY expires_at`
+	rows, err := a.db(ctx).Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []watchChannel
+	for rows.Next() {
+		var ch watchChannel
+		if err := rows.Scan(&ch.ID, &ch.UserID, &ch.Provider, &ch.CalendarID,
+			&ch.ResourceID, &ch.TokenHash, &ch.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, ch)
+	}
+	return out, rows.Err()
+}
+
+// watchGoogleCalendar registers a new push channel for userID's calendar.
+// Existing channels are left in place; callers stop them once the new one is live.
+func (a *App) watchGoogleCalendar(ctx context.Context, srv *calendar.Service, userID, calendarID string) (*watchChannel, error) {
+	address := googleWebhookURL()
+	if address == "" {
+		return nil, errPushSyncNotConfigured
+	}
+
+	rawID := make([]byte, 16)
+	if _, err := rand.Read(rawID); err != nil {
+		return nil, err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &calendar.Channel{
+		Id:         hex.EncodeToString(rawID),
+		Type:       "web_hook",
+		Address:    address,
+		Token:      token,
+		Expiration: time.Now().Add(watchChannelTTL).UnixMilli(),
+	}
+	resp, err := srv.Events.Watch(calendarID, req).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &watchChannel{
+		ID:         resp.Id,
+		UserID:     userID,
+		Provider:   providerGoogle,
+		CalendarID: calendarID,
+		ResourceID: resp.ResourceId,
+		TokenHash:  hashChannelToken(token),
+		ExpiresAt:  time.UnixMilli(resp.Expiration).UTC(),
+	}
+	q := `INSERT INTO calendar_watch_channels
+          (id, user_id, provider, calendar_id, resource_id, token_hash, expires_at, created_at)
+          VALUES ($1, $2, $3, $4, $5, $6, $7, now())`
+	if _, err := a.db(ctx).Exec(ctx, q, ch.ID, ch.UserID, ch.Provider, ch.CalendarID,
+		ch.ResourceID, ch.TokenHash, ch.ExpiresAt); err != nil {
+		// don't leave a channel Google will deliver to but we can't verify
+		_ = srv.Channels.Stop(&calendar.Channel{Id: ch.ID, ResourceId: ch.ResourceID}).Context(ctx).Do()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// stopWatchChannel stops ch at Google and forgets it. A channel Google no
+// longer knows about is simply removed.
+func (a *App) stopWatchChannel(ctx context.Context, srv *calendar.Service, ch watchChannel) error {
+	if srv != nil {
+		err := srv.Channels.Stop(&calendar.Channel{Id: ch.ID, ResourceId: ch.ResourceID}).Context(ctx).Do()
+		var gerr *googleapi.Error
+		if err != nil && !(errors.As(err, &gerr) && gerr.Code == http.StatusNotFound) {
+			return err
+		}
+	}
+	_, err := a.db(ctx).Exec(ctx, `DELETE FROM calendar_watch_channels WHERE id=$1`, ch.ID)
+	return err
+}
+
+// syncGoogleCalendar pulls changes for one calendar into external_events using
+// the stored sync token, falling back to a full sync when Google expires it.
+// Pages are fetched before the transaction opens, and only applied if no other
+// sync advanced the token meanwhile; otherwise the pull starts over from it.
+func (a *App) syncGoogleCalendar(ctx context.Context, srv *calendar.Service, userID, calendarID string) error {
+	for attempt := 0; ; attempt++ {
+		syncToken, err := a.googleSyncToken(ctx, a.db(ctx), userID, calendarID)
+		if err != nil {
+			return err
+		}
+
+		changes, err := pullGoogleChanges(ctx, srv, calendarID, syncToken)
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusGone {
+			// sync token invalidated: start over with a full sync
+			changes, err = pullGoogleChanges(ctx, srv, calendarID, "")
+		}
+		if err != nil {
+			return err
+		}
+
+		applied, err := a.applyGoogleChanges(ctx, userID, calendarID, syncToken, changes)
+		if err != nil || applied {
+			return err
+		}
+		if attempt == maxSyncAttempts-1 {
+			return errors.New("calendar sync: sync token kept changing")
+		}
+	}
+}
+
+// googleSyncToken returns the stored sync token for a calendar, or "" when it
+// has never completed a sync.
+func (a *App) googleSyncToken(ctx context.Context, q querier, userID, calendarID string) (string, error) {
+	var syncToken *string
+	err := q.QueryRow(ctx, `SELECT sync_token FROM calendar_sync_state
+	                        WHERE user_id=$1 AND provider=$2 AND calendar_id=$3`,
+		userID, providerGoogle, calendarID).Scan(&syncToken)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+	return derefString(syncToken), nil
+}
+
+// googleChanges is one pull of a calendar's changes. full means the items are
+// every recent event rather than changes since the previous token.
+type googleChanges struct {
+	items []googleChange
+	next  string
+	full  bool
+}
+
+type googleChange struct {
+	item *calendar.Event
+	loc  *time.Location
+}
+
+// applyGoogleChanges writes changes pulled from syncToken in one transaction,
+// serialized per calendar. It reports false, writing nothing, when the stored
+// token is no longer syncToken.
+func (a *App) applyGoogleChanges(ctx context.Context, userID, calendarID, syncToken string, changes googleChanges) (bool, error) {
+	tx, err := a.db(ctx).Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('calendar_sync:' || $1::text || ':' || $2::text))`,
+		userID, calendarID); err != nil {
+		return false, err
+	}
+	current, err := a.googleSyncToken(ctx, tx, userID, calendarID)
+	if err != nil {
+		return false, err
+	}
+	if current != syncToken {
+		return false, nil
+	}
+
+	if changes.full {
+		if _, err := tx.Exec(ctx, `DELETE FROM external_events WHERE user_id=$1 AND provider=$2 AND calendar_id=$3`,
+			userID, providerGoogle, calendarID); err != nil {
+			return false, err
+		}
+	}
+	for _, ch := range changes.items {
+		if err := applyGoogleEvent(ctx, tx, userID, calendarID, ch.item, ch.loc); err != nil {
+			return false, err
+		}
+	}
+
+	q := `INSERT INTO calendar_sync_state (user_id, provider, calendar_id, sync_token, last_synced_at)
+          VALUES ($1, $2, $3, $4, now())
+          ON CONFLICT (user_id, provider, calendar_id) DO UPDATE
+          SET sync_token=EXCLUDED.sync_token, last_synced_at=EXCLUDED.last_synced_at`
+	if _, err := tx.Exec(ctx, q, userID, providerGoogle, calendarID, nullIfEmpty(changes.next)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// pullGoogleChanges fetches every page of changes since syncToken, or of all
+// recent events when syncToken is empty, together with the next sync token.
+func pullGoogleChanges(ctx context.Context, srv *calendar.Service, calendarID, syncToken string) (googleChanges, error) {
+	out := googleChanges{full: syncToken == ""}
+	pageToken := ""
+	for {
+		call := srv.Events.List(calendarID).SingleEvents(true).ShowDeleted(true).MaxResults(2500).Context(ctx)
+		if syncToken != "" {
+			call = call.SyncToken(syncToken)
+		} else {
+			call = call.TimeMin(time.Now().Add(-syncLookback).UTC().Format(time.RFC3339))
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		page, err := call.Do()
+		if err != nil {
+			return googleChanges{}, err
+		}
+
+		// all-day events are dates in the calendar's own timezone
+		loc, err := loadTimezone(page.TimeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+		for _, item := range page.Items {
+			out.items = append(out.items, googleChange{item: item, loc: loc})
+		}
+
+		if page.NextPageToken == "" {
+			out.next = page.NextSyncToken
+			return out, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// applyGoogleEvent upserts or removes one changed event in external_events.
+func applyGoogleEvent(ctx context.Context, q querier, userID, calendarID string, item *calendar.Event, loc *time.Location) error {
+	if item.Status == "cancelled" {
+		_, err := q.Exec(ctx, `DELETE FROM external_events
+		                       WHERE user_id=$1 AND provider=$2 AND calendar_id=$3 AND event_id=$4`,
+			userID, providerGoogle, calendarID, item.Id)
+		return err
+	}
+
+	span, allDay, err := googleEventSpan(item, loc)
+	if err != nil {
+		log.Printf("calendar sync: skip event %s for user %s: %v", item.Id, userID, err)
+		return nil
+	}
+
+	ins := `INSERT INTO external_events
+            (user_id, provider, calendar_id, event_id, start_at_utc, end_at_utc, all_day, transparent, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+            ON CONFLICT (user_id, provider, calendar_id, event_id) DO UPDATE
+            SET start_at_utc=EXCLUDED.start_at_utc, end_at_utc=EXCLUDED.end_at_utc,
+                all_day=EXCLUDED.all_day, transparent=EXCLUDED.transparent, updated_at=now()`
+	_, err = q.Exec(ctx, ins, userID, providerGoogle, calendarID, item.Id,
+		span.Start, span.End, allDay, !googleEventBlocks(item))
+	return err
+}
+
+// googleEventSpan returns an event's UTC span. All-day events cover whole local
+// days in loc, with an exclusive end date.
+func googleEventSpan(item *calendar.Event, loc *time.Location) (interval, bool, error) {
+	if item.Start == nil || item.End == nil {
+		return interval{}, false, errors.New("event has no start or end")
+	}
+	if item.Start.DateTime != "" {
+		start, err := time.Parse(time.RFC3339, item.Start.DateTime)
+		if err != nil {
+			return interval{}, false, err
+		}
+		end, err := time.Parse(time.RFC3339, item.End.DateTime)
+		if err != nil {
+			return interval{}, false, err
+		}
+		return interval{Start: start.UTC(), End: end.UTC()}, false, nil
+	}
+
+	start, err := time.Parse(dateLayout, item.Start.Date)
+	if err != nil {
+		return interval{}, false, err
+	}
+	end, err := time.Parse(dateLayout, item.End.Date)
+	if err != nil {
+		return interval{}, false, err
+	}
+	return interval{Start: wallClock(start, time.Time{}, loc), End: wallClock(end, time.Time{}, loc)}, true, nil
+}
+
+// googleEventBlocks reports whether an event makes its owner busy. Free events,
+// invitations the owner declined, and events mirrored from our own bookings
+// (already blocked locally, with buffers) do not.
+func googleEventBlocks(item *calendar.Event) bool {
+	if item.Transparency == "transparent" {
+		return false
+	}
+	if item.ExtendedProperties != nil && item.ExtendedProperties.Private["booking_id"] != "" {
+		return false
+	}
+	return !googleSelfDeclined(item)
+}
+
+// googleSelfDeclined reports whether item is an invitation the calendar's
+// owner declined.
+func googleSelfDeclined(item *calendar.Event) bool {
+	for _, att := range item.Attendees {
+		if att.Self && att.ResponseStatus == "declined" {
+			return true
+		}
+	}
+	return false
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// cachedBusy returns busy periods from external_events when every calendar in
+// calendarIDs has completed a sync and is covered by a live watch channel.
+// ok is false when the cache can't be trusted and callers must ask the provider.
+func (a *App) cachedBusy(ctx context.Context, userID, provider string, calendarIDs []string, from, to time.Time) (periods []BusyPeriod, ok bool, err error) {
+	var synced int
+	q := `SELECT count(*) FROM calendar_sync_state s
+	      WHERE s.user_id=$1 AND s.provider=$2 AND s.calendar_id = ANY($3) AND s.sync_token IS NOT NULL
+	        AND EXISTS (SELECT 1 FROM calendar_watch_channels w
+	                    WHERE w.user_id=s.user_id AND w.provider=s.provider
+	                      AND w.calendar_id=s.calendar_id AND w.expires_at > now())`
+	if err := a.db(ctx).QueryRow(ctx, q, userID, provider, calendarIDs).Scan(&synced); err != nil {
+		return nil, false, err
+	}
+	if synced < len(calendarIDs) {
+		return nil, false, nil
+	}
+
+	rows, err := a.db(ctx).Query(ctx, `SELECT start_at_utc, end_at_utc FROM external_events
+	                              WHERE user_id=$1 AND provider=$2 AND calendar_id = ANY($3) AND NOT transparent
+	                                AND start_at_utc < $5 AND end_at_utc > $4
+	                              ORDER BY start_at_utc`,
+		userID, provider, calendarIDs, from, to)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p BusyPeriod
+		if err := rows.Scan(&p.Start, &p.End); err != nil {
+			return nil, false, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, true, rows.Err()
+}
+
+// clearCalendarSync drops a user's sync state and cached events for provider,
+// limited to calendarIDs when any are given.
+func (a *App) clearCalendarSync(ctx context.Context, userID, provider string, calendarIDs ...string) error {
+	if _, err := a.db(ctx).Exec(ctx, `DELETE FROM calendar_sync_state
+	                             WHERE user_id=$1 AND provider=$2 AND ($3::text[] IS NULL OR calendar_id = ANY($3))`,
+		userID, provider, calendarIDs); err != nil {
+		return err
+	}
+	_, err := a.db(ctx).Exec(ctx, `DELETE FROM external_events
+	                          WHERE user_id=$1 AND provider=$2 AND ($3::text[] IS NULL OR calendar_id = ANY($3))`,
+		userID, provider, calendarIDs)
+	return err
+}
+
+// watchCalendars registers a channel for each of calendarIDs, then stops the
+// existing channels they replace and those of calendars no longer listed, whose
+// cached events are dropped. Unless renew is set, a calendar that already has a
+// channel keeps it. Each newly watched calendar is synced; synced reports
+// whether all of those syncs succeeded.
+func (a *App) watchCalendars(ctx context.Context, srv *calendar.Service, userID string, calendarIDs []string,
+	existing []watchChannel, renew bool) (created []watchChannel, synced bool, err error) {
+	watched := make(map[string][]watchChannel)
+	for _, ch := range existing {
+		watched[ch.CalendarID] = append(watched[ch.CalendarID], ch)
+	}
+
+	synced = true
+	for _, id := range calendarIDs {
+		old := watched[id]
+		delete(watched, id)
+		if !renew && len(old) > 0 {
+			continue
+		}
+
+		ch, err := a.watchGoogleCalendar(ctx, srv, userID, id)
+		if err != nil {
+			return created, false, err
+		}
+		created = append(created, *ch)
+		a.stopWatchChannels(ctx, srv, old)
+
+		// the channel is live even if the first sync fails; the next notification retries it
+		if err := a.syncGoogleCalendar(ctx, srv, userID, id); err != nil {
+			log.Printf("calendar sync: sync user %s calendar %s: %v", userID, id, err)
+			synced = false
+		}
+	}
+
+	for id, old := range watched {
+		a.stopWatchChannels(ctx, srv, old)
+		if err := a.clearCalendarSync(ctx, userID, providerGoogle, id); err != nil {
+			return created, synced, err
+		}
+	}
+	return created, synced, nil
+}
+
+// rewatchSelectedCalendars moves an active push sync onto calendarIDs. It does
+// nothing for users who have not enabled push sync.
+func (a *App) rewatchSelectedCalendars(ctx context.Context, srv *calendar.Service, userID string, calendarIDs []string) error {
+	existing, err := a.listWatchChannels(ctx, "user_id=$1 AND provider=$2", userID, providerGoogle)
+	if err != nil || len(existing) == 0 {
+		return err
+	}
+	_, _, err = a.watchCalendars(ctx, srv, userID, calendarIDs, existing, false)
+	return err
+}
+
+// stopWatchChannels stops channels, logging failures.
+func (a *App) stopWatchChannels(ctx context.Context, srv *calendar.Service, channels []watchChannel) {
+	for _, ch := range channels {
+		if err := a.stopWatchChannel(ctx, srv, ch); err != nil {
+			log.Printf("calendar sync: stop channel %s: %v", ch.ID, err)
+		}
+	}
+}
+
+// RunCalendarChannelRenewal replaces watch channels that are about to expire,
+// checking every interval until ctx is done.
+func (a *App) RunCalendarChannelRenewal(ctx context.Context, every time.Duration) {
+	ctx = asSystem(ctx)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		a.renewExpiringChannels(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *App) renewExpiringChannels(ctx context.Context) {
+	cfg := InitGoogleCalendarConfig()
+	if cfg == nil || googleWebhookURL() == "" {
+		return
+	}
+	channels, err := a.listWatchChannels(ctx, "provider=$1 AND expires_at < $2",
+		providerGoogle, time.Now().Add(watchRenewBefore))
+	if err != nil {
+		log.Printf("calendar sync: list expiring channels: %v", err)
+		return
+	}
+
+	for _, old := range channels {
+		srv, err := a.googleCalendarService(ctx, cfg, old.UserID)
+		if errors.Is(err, errNoCalendarConnection) {
+			// connection removed; the channel can't be stopped without a token
+			if err := a.stopWatchChannel(ctx, nil, old); err != nil {
+				log.Printf("calendar sync: drop channel %s: %v", old.ID, err)
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("calendar sync: renew channel %s: %v", old.ID, err)
+			continue
+		}
+
+		if _, err := a.watchGoogleCalendar(ctx, srv, old.UserID, old.CalendarID); err != nil {
+			log.Printf("calendar sync: renew channel %s: %v", old.ID, err)
+			continue
+		}
+		if err := a.stopWatchChannel(ctx, srv, old); err != nil {
+			log.Printf("calendar sync: stop channel %s: %v", old.ID, err)
+		}
+		// catch anything that changed while no channel was delivering
+		if err := a.syncGoogleCalendar(ctx, srv, old.UserID, old.CalendarID); err != nil {
+			log.Printf("calendar sync: sync user %s calendar %s: %v", old.UserID, old.CalendarID, err)
+		}
+	}
+}
+
+// GoogleCalendarWebhookHandler receives push notifications from Google.
+// It is unauthenticated; the channel ID, token and resource ID must match a
+// registered channel.
+// POST /webhooks/google/calendar
+func (a *App) GoogleCalendarWebhookHandler(c *gin.Context) {
+	channelID := c.GetHeader("X-Goog-Channel-ID")
+	token := c.GetHeader("X-Goog-Channel-Token")
+	if channelID == "" || token == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	ch, err := a.getWatchChannel(asSystem(c.Request.Context()), channelID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if subtle.ConstantTimeCompare(hashChannelToken(token), ch.TokenHash) != 1 ||
+		c.GetHeader("X-Goog-Resource-ID") != ch.ResourceID {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	// "sync" only confirms the channel was created
+	if c.GetHeader("X-Goog-Resource-State") == "sync" {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	// acknowledge immediately; Google retries slow deliveries
+	a.webhookSyncs.trigger(ch.ID, func() { a.syncWatchedCalendar(*ch) })
+	c.Status(http.StatusOK)
+}
+
+// syncWatchedCalendar syncs the calendar behind a push channel in the background.
+func (a *App) syncWatchedCalendar(ch watchChannel) {
+	ctx, cancel := context.WithTimeout(asSystem(context.Background()), webhookSyncTimeout)
+	defer cancel()
+	cfg := InitGoogleCalendarConfig()
+	if cfg == nil {
+		return
+	}
+	srv, err := a.googleCalendarService(ctx, cfg, ch.UserID)
+	if err == nil {
+		err = a.syncGoogleCalendar(ctx, srv, ch.UserID, ch.CalendarID)
+	}
+	if err != nil {
+		log.Printf("calendar sync: sync user %s calendar %s: %v", ch.UserID, ch.CalendarID, err)
+	}
+}
+
+// syncCoalescer runs at most one background sync per key. Triggers that arrive
+// while one runs collapse into a single rerun after it finishes, so a burst of
+// notifications costs two syncs rather than one goroutine each.
+type syncCoalescer struct {
+	mu      sync.Mutex
+	pending map[string]bool // keys with a running sync; true when a rerun is due
+}
+
+func (s *syncCoalescer) trigger(key string, run func()) {
+	s.mu.Lock()
+	if _, running := s.pending[key]; running {
+		s.pending[key] = true
+		s.mu.Unlock()
+		return
+	}
+	if s.pending == nil {
+		s.pending = make(map[string]bool)
+	}
+	s.pending[key] = false
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			run()
+			s.mu.Lock()
+			if !s.pending[key] {
+				delete(s.pending, key)
+				s.mu.Unlock()
+				return
+			}
+			s.pending[key] = false
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// WatchGoogleCalendarHandler starts push sync for a user's conflict calendars,
+// replacing any existing channels, and syncs each calendar.
+// POST /api/calendar/watch
+func (a *App) WatchGoogleCalendarHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
+		return
+	}
+
+	calendarConfig := InitGoogleCalendarConfig()
+	if calendarConfig == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Google Calendar not configured"})
+		return
+	}
+	if googleWebhookURL() == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errPushSyncNotConfigured.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	srv, err := a.googleCalendarService(ctx, calendarConfig, userID)
+	if err != nil {
+		respondCalendarServiceError(c, err)
+		return
+	}
+
+	existing, err := a.listWatchChannels(ctx, "user_id=$1 AND provider=$2", userID, providerGoogle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sel, err := a.GetCalendarSelection(ctx, userID, providerGoogle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(sel.ConflictCalendarIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no conflict calendars selected"})
+		return
+	}
+
+	channels, synced, err := a.watchCalendars(ctx, srv, userID, sel.ConflictCalendarIDs, existing, true)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to register watch channel"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(channels))
+	for _, ch := range channels {
+		out = append(out, gin.H{
+			"channel_id":  ch.ID,
+			"calendar_id": ch.CalendarID,
+			"expires_at":  ch.ExpiresAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"channels": out,
+		"synced":   synced,
+	})
+}
+
+// UnwatchGoogleCalendarHandler stops push sync for a user and drops the cache,
+// so busy time is looked up live again.
+// DELETE /api/calendar/watch
+func (a *App) UnwatchGoogleCalendarHandler(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var srv *calendar.Service
+	if cfg := InitGoogleCalendarConfig(); cfg != nil {
+		s, err := a.googleCalendarService(ctx, cfg, userID)
+		if err != nil && !errors.Is(err, errNoCalendarConnection) {
+			respondCalendarServiceError(c, err)
+			return
+		}
+		srv = s
+	}
+
+	channels, err := a.listWatchChannels(ctx, "user_id=$1 AND provider=$2", userID, providerGoogle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, ch := range channels {
+		if err := a.stopWatchChannel(ctx, srv, ch); err != nil {
+			log.Printf("calendar sync: stop channel %s: %v", ch.ID, err)
+			// forget it anyway; notifications for unknown channels are rejected
+			if _, err := a.db(ctx).Exec(ctx, `DELETE FROM calendar_watch_channels WHERE id=$1`, ch.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	if err := a.clearCalendarSync(ctx, userID, providerGoogle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
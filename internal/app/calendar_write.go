@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+)
+
+// locationGoogleMeet as an event type location requests a Meet conference on the calendar event.
+const locationGoogleMeet = "google_meet"
+
+// googleWriteEnabled reports whether GOOGLE_CALENDAR_WRITE opts into writing
+// bookings to the host's Google Calendar. It also adds the events scope to the
+// OAuth flow, so hosts connected earlier must reconnect.
+func googleWriteEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("GOOGLE_CALENDAR_WRITE"))
+	return enabled
+}
+
+// ExternalEventRef locates the calendar event mirroring a booking.
+type ExternalEventRef struct {
+	Provider   string
+	CalendarID string
+	EventID    string
+}
+
+// errCalendarWriteDisabled is returned when write-back is off for the target provider.
+var errCalendarWriteDisabled = errors.New("calendar write-back disabled")
+
+// CalendarWriter mirrors bookings as events on the host's calendar.
+// Implementations return errNoCalendarConnection for hosts without a connection.
+type CalendarWriter interface {
+	CreateEvent(ctx context.Context, b Booking, et *EventType) (ExternalEventRef, error)
+	UpdateEvent(ctx context.Context, b Booking) error
+	DeleteEvent(ctx context.Context, b Booking) error
+}
+
+// calendarWriter returns a.Writer, defaulting to the host's target calendar.
+func (a *App) calendarWriter() CalendarWriter {
+	if a.Writer != nil {
+		return a.Writer
+	}
+	return &providerCalendarWriter{app: a}
+}
+
+// skipWriteError reports whether a write-back failure just means there is nothing to write to.
+func skipWriteError(err error) bool {
+	return errors.Is(err, errNoCalendarConnection) || errors.Is(err, errCalendarWriteDisabled)
+}
+
+// syncCreatedBooking creates the calendar event for a new booking and stores its
+// ID on the booking row. Calendar failures are logged; the booking stands.
+func (a *App) syncCreatedBooking(ctx context.Context, b Booking, et *EventType) (eventID string) {
+	ref, err := a.calendarWriter().CreateEvent(ctx, b, et)
+	if skipWriteError(err) {
+		return ""
+	}
+	if err != nil {
+		log.Printf("calendar: create event for booking %s: %v", b.ID, err)
+		return ""
+	}
+	if err := a.SetBookingExternalEvent(ctx, b.ID, ref); err != nil {
+		log.Printf("calendar: record event %s for booking %s: %v", ref.EventID, b.ID, err)
+	}
+	return ref.EventID
+}
+
+// syncRescheduledBooking moves the calendar event of a rescheduled booking.
+func (a *App) syncRescheduledBooking(ctx context.Context, b Booking) {
+	if b.ExternalEventID == "" {
+		return
+	}
+	if err := a.calendarWriter().UpdateEvent(ctx, b); err != nil && !skipWriteError(err) {
+		log.Printf("calendar: update event for booking %s: %v", b.ID, err)
+	}
+}
+
+// syncCancelledBooking removes the calendar event of a cancelled booking.
+func (a *App) syncCancelledBooking(ctx context.Context, b Booking) {
+	if b.ExternalEventID == "" {
+		return
+	}
+	if err := a.calendarWriter().DeleteEvent(ctx, b); err != nil && !skipWriteError(err) {
+		log.Printf("calendar: delete event for booking %s: %v", b.ID, err)
+	}
+}
+
+// bookingSummary is the calendar event title for a booking.
+func bookingSummary(b Booking, et *EventType) string {
+	switch {
+	case b.Title != "":
+		return b.Title
+	case et != nil:
+		return et.Name + " with " + b.CandidateEmail
+	default:
+		return "Meeting with " + b.CandidateEmail
+	}
+}
+
+// bookingEventInput describes the calendar event for a booking.
+func bookingEventInput(b Booking, et *EventType) EventInput {
+	in := EventInput{
+		Summary:     bookingSummary(b, et),
+		Description: b.Description,
+		Start:       b.StartAtUTC,
+		End:         b.EndAtUTC,
+		Attendees:   []string{b.CandidateEmail},
+		BookingID:   b.ID,
+	}
+	if et != nil && et.Location == locationGoogleMeet {
+		in.Conference = true
+	} else if et != nil {
+		in.Location = et.Location
+	}
+	return in
+}
+
+// providerWriteEnabled reports whether bookings may be written to provider.
+// OAuth providers need their opt-in, since it widens the requested scope.
+func providerWriteEnabled(provider string) bool {
+	switch provider {
+	case providerGoogle:
+		return googleWriteEnabled()
+	case providerMicrosoft:
+		return microsoftWriteEnabled()
+	}
+	return true
+}
+
+// providerCalendarWriter writes events to the host's target calendar through
+// its provider, where write-back is enabled for that provider.
+type providerCalendarWriter struct {
+	app *App
+}
+
+// provider opens name for userID, honouring the provider's write opt-in.
+func (w *providerCalendarWriter) provider(ctx context.Context, userID, name string) (CalendarProvider, error) {
+	if !providerWriteEnabled(name) {
+		return nil, errCalendarWriteDisabled
+	}
+	return w.app.calendarProvider(ctx, userID, name)
+}
+
+func (w *providerCalendarWriter) CreateEvent(ctx context.Context, b Booking, et *EventType) (ExternalEventRef, error) {
+	name, calendarID, err := w.app.writeTarget(ctx, b.UserID)
+	if err != nil {
+		return ExternalEventRef{}, err
+	}
+	p, err := w.provider(ctx, b.UserID, name)
+	if err != nil {
+		return ExternalEventRef{}, err
+	}
+	eventID, err := p.CreateEvent(ctx, calendarID, bookingEventInput(b, et))
+	if err != nil {
+		return ExternalEventRef{}, err
+	}
+	return ExternalEventRef{Provider: name, CalendarID: calendarID, EventID: eventID}, nil
+}
+
+func (w *providerCalendarWriter) UpdateEvent(ctx context.Context, b Booking) error {
+	p, err := w.provider(ctx, b.UserID, b.ExternalProvider)
+	if err != nil {
+		return err
+	}
+	return p.UpdateEvent(ctx, b.ExternalCalendarID, b.ExternalEventID, b.StartAtUTC, b.EndAtUTC)
+}
+
+func (w *providerCalendarWriter) DeleteEvent(ctx context.Context, b Booking) error {
+	p, err := w.provider(ctx, b.UserID, b.ExternalProvider)
+	if err != nil {
+		return err
+	}
+	return p.DeleteEvent(ctx, b.ExternalCalendarID, b.ExternalEventID)
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sealedVersion prefixes every sealed value so the format can change later.
+const sealedVersion byte = 1
+
+// tokenCipher encrypts secrets at rest with AES-256-GCM.
+type tokenCipher struct {
+	aead cipher.AEAD
+}
+
+// tokenCipherFromEnv builds a cipher from TOKEN_ENCRYPTION_KEY, a base64-encoded 32-byte key.
+func tokenCipherFromEnv() (*tokenCipher, error) {
+	raw := strings.TrimSpace(os.Getenv("TOKEN_ENCRYPTION_KEY"))
+	if raw == "" {
+		return nil, errors.New("TOKEN_ENCRYPTION_KEY not configured")
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY must be base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY must decode to 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenCipher{aead: aead}, nil
+}
+
+// seal encrypts plaintext. aad binds the ciphertext to its owner (e.g. user and
+// provider) so a sealed value cannot be moved to another row.
+func (c *tokenCipher) seal(plaintext, aad string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{sealedVersion}, nonce...)
+	return c.aead.Seal(out, nonce, []byte(plaintext), []byte(aad)), nil
+}
+
+// open decrypts a value produced by seal with the same aad.
+func (c *tokenCipher) open(sealed []byte, aad string) (string, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < 1+n || sealed[0] != sealedVersion {
+		return "", errors.New("malformed sealed value")
+	}
+	plain, err := c.aead.Open(nil, sealed[1:1+n], sealed[1+n:], []byte(aad))
+	if err != nil {
+		return "", errors.New("failed to decrypt sealed value")
+	}
+	return string(plain), nil
+}
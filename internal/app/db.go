@@ -2,30 +2,42 @@ package app
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// exclusionViolation is the SQLSTATE raised when an EXCLUDE constraint rejects a row.
+const exclusionViolation = "23P01"
+
+// isExclusionViolation reports whether err came from an EXCLUDE constraint,
+// e.g. bookings_no_overlap rejecting a double booking.
+func isExclusionViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == exclusionViolation
+}
+
 func (a *App) InsertAvailabilityRule(ctx context.Context, r *AvailabilityRule) error {
 	now := time.Now().UTC()
 
 	// Insert - no uniqueness check, allow multiple rules per day
 	q := `INSERT INTO availability_rules
-          (id, user_id, day_of_week, start_time, end_time, slot_length_minutes, title, available, created_at, updated_at)
-          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+          (id, user_id, day_of_week, start_time, end_time, slot_length_minutes, slot_increment_minutes, timezone, title, available, created_at, updated_at, tenant_id)
+          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
 
-	row := a.DB.QueryRow(ctx, q,
-		r.UserID, r.DayOfWeek, r.StartTime, r.EndTime, r.SlotLengthMins,
-		r.Title, r.Available, now, now)
+	row := a.db(ctx).QueryRow(ctx, q,
+		r.UserID, r.DayOfWeek, r.StartTime, r.EndTime, r.SlotLengthMins, nullIfZero(r.SlotIncrement), r.Timezone,
+		r.Title, r.Available, now, now, tenantArg(ctx))
 
 	return row.Scan(&r.ID)
 }
 
 func (a *App) ListAvailabilityRules(ctx context.Context, userID string) ([]AvailabilityRule, error) {
-	q := `SELECT id,user_id,day_of_week,start_time,end_time,slot_length_minutes,title,available,created_at,updated_at
-	      FROM availability_rules WHERE user_id=$1 ORDER BY id`
-	rows, err := a.DB.Query(ctx, q, userID)
+	q := `SELECT id,user_id,day_of_week,start_time,end_time,slot_length_minutes,COALESCE(slot_increment_minutes,0),timezone,title,available,created_at,updated_at
+	      FROM availability_rules WHERE user_id=$1 AND tenant_id=$2 ORDER BY id`
+	rows, err := a.db(ctx).Query(ctx, q, userID, tenantArg(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -36,7 +48,7 @@ func (a *App) ListAvailabilityRules(ctx context.Context, userID string) ([]Avail
 		var r AvailabilityRule
 		var start, end string
 		if err := rows.Scan(&r.ID, &r.UserID, &r.DayOfWeek, &start, &end,
-			&r.SlotLengthMins, &r.Title, &r.Available, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			&r.SlotLengthMins, &r.SlotIncrement, &r.Timezone, &r.Title, &r.Available, &r.CreatedAt, &r.UpdatedAt); err != nil {
 			return nil, err
 		}
 		r.StartTime = start
@@ -46,11 +58,24 @@ func (a *App) ListAvailabilityRules(ctx context.Context, userID string) ([]Avail
 	return out, nil
 }
 
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ListBookingsInRange returns confirmed bookings that overlap [from, to).
 func (a *App) ListBookingsInRange(ctx context.Context, userID string, from, to time.Time) ([]Booking, error) {
-	q := `SELECT id,user_id,candidate_email,start_at_utc,end_at_utc,status,created_at 
+	return listConfirmedBookings(ctx, a.db(ctx), userID, from, to)
+}
+
+func listConfirmedBookings(ctx context.Context, db querier, userID string, from, to time.Time) ([]Booking, error) {
+	q := `SELECT id,user_id,candidate_email,start_at_utc,end_at_utc,status,buffer_before_minutes,buffer_after_minutes,created_at 
 	      FROM bookings
-	      WHERE user_id=$1 AND start_at_utc >= $2 AND start_at_utc < $3 AND status='confirmed'`
-	rows, err := a.DB.Query(ctx, q, userID, from, to)
+	      WHERE user_id=$1 AND start_at_utc < $3 AND end_at_utc > $2 AND status='confirmed'
+	        AND tenant_id=$4`
+	rows, err := db.Query(ctx, q, userID, from, to, tenantArg(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +85,7 @@ func (a *App) ListBookingsInRange(ctx context.Context, userID string, from, to t
 	for rows.Next() {
 		var b Booking
 		if err := rows.Scan(&b.ID, &b.UserID, &b.CandidateEmail,
-			&b.StartAtUTC, &b.EndAtUTC, &b.Status, &b.CreatedAt); err != nil {
+			&b.StartAtUTC, &b.EndAtUTC, &b.Status, &b.BufferBefore, &b.BufferAfter, &b.CreatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, b)
@@ -68,6 +93,13 @@ func (a *App) ListBookingsInRange(ctx context.Context, userID string, from, to t
 	return out, nil
 }
 
+// lockUserBookings takes a transaction-scoped advisory lock for userID so that
+// checks spanning several rows (buffers, caps) cannot race with another booking.
+func lockUserBookings(ctx context.Context, tx pgx.Tx, userID string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('bookings:' || $1::text))`, userID)
+	return err
+}
+
 func (a *App) ListBookings(ctx context.Context, userID string, from, to time.Time, filtered bool) ([]Booking, error) {
 	var (
 		rows pgx.Rows
@@ -78,14 +110,15 @@ func (a *App) ListBookings(ctx context.Context, userID string, from, to time.Tim
 		q := `SELECT id,user_id,candidate_email,start_at_utc,end_at_utc,status,created_at 
               FROM bookings 
               WHERE user_id=$1 AND start_at_utc >= $2 AND start_at_utc < $3 AND status != 'cancelled'
+                AND tenant_id=$4
               ORDER BY start_at_utc`
-		rows, err = a.DB.Query(ctx, q, userID, from, to)
+		rows, err = a.db(ctx).Query(ctx, q, userID, from, to, tenantArg(ctx))
 	} else {
 		q := `SELECT id,user_id,candidate_email,start_at_utc,end_at_utc,status,created_at 
               FROM bookings 
-              WHERE user_id=$1 AND status != 'cancelled'
+              WHERE user_id=$1 AND status != 'cancelled' AND tenant_id=$2
               ORDER BY start_at_utc`
-		rows, err = a.DB.Query(ctx, q, userID)
+		rows, err = a.db(ctx).Query(ctx, q, userID, tenantArg(ctx))
 	}
 	if err != nil {
 		return nil, err
@@ -102,3 +135,343 @@ func (a *App) ListBookings(ctx context.Context, userID string, from, to time.Tim
 	}
 	return out, nil
 }
+
+func (a *App) InsertAvailabilityOverride(ctx context.Context, o *AvailabilityOverride) error {
+	now := time.Now().UTC()
+
+	q := `INSERT INTO availability_overrides
+          (id, user_id, override_date, blocked, start_time, end_time, slot_length_minutes, slot_increment_minutes,
+           timezone, title, created_at, updated_at, tenant_id)
+          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at, updated_at`
+
+	row := a.db(ctx).QueryRow(ctx, q,
+		o.UserID, o.Date, o.Blocked, nullIfEmpty(o.StartTime), nullIfEmpty(o.EndTime),
+		nullIfZero(o.SlotLengthMins), nullIfZero(o.SlotIncrement), o.Timezone, o.Title, now, now, tenantArg(ctx))
+
+	return row.Scan(&o.ID, &o.CreatedAt, &o.UpdatedAt)
+}
+
+// UpdateAvailabilityOverride overwrites an override owned by o.UserID.
+// It returns pgx.ErrNoRows when no such override exists.
+func (a *App) UpdateAvailabilityOverride(ctx context.Context, o *AvailabilityOverride) error {
+	now := time.Now().UTC()
+
+	q := `UPDATE availability_overrides
+          SET override_date=$1, blocked=$2, start_time=$3, end_time=$4, slot_length_minutes=$5,
+              slot_increment_minutes=$6, timezone=$7, title=$8, updated_at=$9
+          WHERE id=$10 AND user_id=$11 AND tenant_id=$12
+          RETURNING created_at, updated_at`
+
+	return a.db(ctx).QueryRow(ctx, q,
+		o.Date, o.Blocked, nullIfEmpty(o.StartTime), nullIfEmpty(o.EndTime),
+		nullIfZero(o.SlotLengthMins), nullIfZero(o.SlotIncrement), o.Timezone, o.Title, now, o.ID, o.UserID,
+		tenantArg(ctx),
+	).Scan(&o.CreatedAt, &o.UpdatedAt)
+}
+
+// DeleteAvailabilityOverride reports whether an override was removed.
+func (a *App) DeleteAvailabilityOverride(ctx context.Context, userID, overrideID string) (bool, error) {
+	res, err := a.db(ctx).Exec(ctx, `DELETE FROM availability_overrides WHERE id=$1 AND user_id=$2 AND tenant_id=$3`,
+		overrideID, userID, tenantArg(ctx))
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// ListAvailabilityOverrides returns overrides whose date falls within [fromDate, toDate].
+// Zero dates leave that side of the range open.
+func (a *App) ListAvailabilityOverrides(ctx context.Context, userID string, fromDate, toDate time.Time) ([]AvailabilityOverride, error) {
+	q := `SELECT id,user_id,override_date,blocked,start_time,end_time,slot_length_minutes,COALESCE(slot_increment_minutes,0),
+	             timezone,COALESCE(title,''),created_at,updated_at
+	      FROM availability_overrides
+	      WHERE user_id=$1 AND tenant_id=$4
+	        AND ($2::date IS NULL OR override_date >= $2)
+	        AND ($3::date IS NULL OR override_date <= $3)
+	      ORDER BY override_date, start_time`
+	rows, err := a.db(ctx).Query(ctx, q, userID, nullIfZeroTime(fromDate), nullIfZeroTime(toDate), tenantArg(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AvailabilityOverride
+	for rows.Next() {
+		var (
+			o          AvailabilityOverride
+			date       time.Time
+			start, end *string
+			slotLen    *int
+		)
+		if err := rows.Scan(&o.ID, &o.UserID, &date, &o.Blocked, &start, &end,
+			&slotLen, &o.SlotIncrement, &o.Timezone, &o.Title, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		o.Date = date.Format(dateLayout)
+		if start != nil {
+			o.StartTime = *start
+		}
+		if end != nil {
+			o.EndTime = *end
+		}
+		if slotLen != nil {
+			o.SlotLengthMins = *slotLen
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullIfZero(n int) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func nullIfZeroTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// GetUserSettings returns the stored settings for userID, or defaults in the
+// user's own timezone when none exist. Unknown users give errUserNotFound.
+func (a *App) GetUserSettings(ctx context.Context, userID string) (UserSettings, error) {
+	q := `SELECT user_id,buffer_before_minutes,buffer_after_minutes,min_notice_minutes,booking_horizon_days,
+	             max_bookings_per_day,max_bookings_per_week,timezone,created_at,updated_at
+	      FROM user_settings WHERE user_id=$1`
+	s := UserSettings{UserID: userID}
+	err := a.db(ctx).QueryRow(ctx, q, userID).Scan(&s.UserID, &s.BufferBefore, &s.BufferAfter,
+		&s.MinNotice, &s.HorizonDays, &s.MaxPerDay, &s.MaxPerWeek, &s.Timezone, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		s.Timezone, err = a.userTimezone(ctx, userID)
+	}
+	return s, err
+}
+
+func (a *App) UpsertUserSettings(ctx context.Context, s *UserSettings) error {
+	now := time.Now().UTC()
+
+	q := `INSERT INTO user_settings
+          (user_id, buffer_before_minutes, buffer_after_minutes, min_notice_minutes, booking_horizon_days,
+           max_bookings_per_day, max_bookings_per_week, timezone, created_at, updated_at)
+          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+          ON CONFLICT (user_id) DO UPDATE
+          SET buffer_before_minutes=EXCLUDED.buffer_before_minutes,
+              buffer_after_minutes=EXCLUDED.buffer_after_minutes,
+              min_notice_minutes=EXCLUDED.min_notice_minutes,
+              booking_horizon_days=EXCLUDED.booking_horizon_days,
+              max_bookings_per_day=EXCLUDED.max_bookings_per_day,
+              max_bookings_per_week=EXCLUDED.max_bookings_per_week,
+              timezone=EXCLUDED.timezone,
+              updated_at=EXCLUDED.updated_at
+          RETURNING created_at, updated_at`
+
+	return a.db(ctx).QueryRow(ctx, q, s.UserID, s.BufferBefore, s.BufferAfter, s.MinNotice, s.HorizonDays,
+		s.MaxPerDay, s.MaxPerWeek, s.Timezone, now).Scan(&s.CreatedAt, &s.UpdatedAt)
+}
+
+// errUnknownRule is returned when an event type references a rule the user does not own.
+var errUnknownRule = errors.New("rule_ids must reference the user's availability rules")
+
+// uniqueViolation is the SQLSTATE raised when a UNIQUE constraint rejects a row.
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// foreignKeyViolation is the SQLSTATE raised when a row references a missing one.
+const foreignKeyViolation = "23503"
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation
+}
+
+func (a *App) InsertEventType(ctx context.Context, et *EventType) error {
+	tx, err := a.beginTenantTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	q := `INSERT INTO event_types
+          (id, user_id, name, slug, duration_minutes, buffer_before_minutes, buffer_after_minutes, location, created_at, updated_at, tenant_id)
+          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $8, $9) RETURNING id, created_at, updated_at`
+	if err := tx.QueryRow(ctx, q, et.UserID, et.Name, et.Slug, et.DurationMins,
+		et.BufferBefore, et.BufferAfter, et.Location, now, tenantArg(ctx)).Scan(&et.ID, &et.CreatedAt, &et.UpdatedAt); err != nil {
+		return err
+	}
+	if err := replaceEventTypeRules(ctx, tx, et); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateEventType overwrites an event type owned by et.UserID, including its rule restriction.
+// It returns pgx.ErrNoRows when no such event type exists.
+func (a *App) UpdateEventType(ctx context.Context, et *EventType) error {
+	tx, err := a.beginTenantTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `UPDATE event_types
+          SET name=$1, slug=$2, duration_minutes=$3, buffer_before_minutes=$4, buffer_after_minutes=$5,
+              location=$6, updated_at=$7
+          WHERE id=$8 AND user_id=$9 AND tenant_id=$10
+          RETURNING created_at, updated_at`
+	if err := tx.QueryRow(ctx, q, et.Name, et.Slug, et.DurationMins, et.BufferBefore, et.BufferAfter,
+		et.Location, time.Now().UTC(), et.ID, et.UserID, tenantArg(ctx)).Scan(&et.CreatedAt, &et.UpdatedAt); err != nil {
+		return err
+	}
+	if err := replaceEventTypeRules(ctx, tx, et); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replaceEventTypeRules(ctx context.Context, tx pgx.Tx, et *EventType) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM event_type_rules WHERE event_type_id=$1`, et.ID); err != nil {
+		return err
+	}
+	if len(et.RuleIDs) == 0 {
+		return nil
+	}
+	res, err := tx.Exec(ctx, `INSERT INTO event_type_rules (event_type_id, rule_id)
+	                          SELECT $1, id FROM availability_rules
+	                          WHERE user_id=$2 AND id = ANY($3::text[]::uuid[]) AND tenant_id=$4`,
+		et.ID, et.UserID, et.RuleIDs, tenantArg(ctx))
+	if err != nil {
+		return err
+	}
+	if int(res.RowsAffected()) != len(et.RuleIDs) {
+		return errUnknownRule
+	}
+	return nil
+}
+
+// DeleteEventType reports whether an event type was removed.
+func (a *App) DeleteEventType(ctx context.Context, userID, eventTypeID string) (bool, error) {
+	res, err := a.db(ctx).Exec(ctx, `DELETE FROM event_types WHERE id=$1 AND user_id=$2 AND tenant_id=$3`,
+		eventTypeID, userID, tenantArg(ctx))
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+const eventTypeColumns = `et.id, et.user_id, et.name, et.slug, et.duration_minutes, et.buffer_before_minutes,
+	et.buffer_after_minutes, COALESCE(et.location,''), et.created_at, et.updated_at,
+	COALESCE((SELECT array_agg(r.rule_id::text ORDER BY r.rule_id) FROM event_type_rules r WHERE r.event_type_id = et.id), '{}')`
+
+func scanEventType(row pgx.Row) (EventType, error) {
+	var et EventType
+	err := row.Scan(&et.ID, &et.UserID, &et.Name, &et.Slug, &et.DurationMins, &et.BufferBefore,
+		&et.BufferAfter, &et.Location, &et.CreatedAt, &et.UpdatedAt, &et.RuleIDs)
+	return et, err
+}
+
+// GetEventType returns pgx.ErrNoRows when userID has no such event type.
+func (a *App) GetEventType(ctx context.Context, userID, eventTypeID string) (EventType, error) {
+	q := `SELECT ` + eventTypeColumns + ` FROM event_types et WHERE et.id=$1 AND et.user_id=$2 AND et.tenant_id=$3`
+	return scanEventType(a.db(ctx).QueryRow(ctx, q, eventTypeID, userID, tenantArg(ctx)))
+}
+
+func (a *App) ListEventTypes(ctx context.Context, userID string) ([]EventType, error) {
+	q := `SELECT ` + eventTypeColumns + ` FROM event_types et WHERE et.user_id=$1 AND et.tenant_id=$2 ORDER BY et.name`
+	rows, err := a.db(ctx).Query(ctx, q, userID, tenantArg(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []EventType
+	for rows.Next() {
+		et, err := scanEventType(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, et)
+	}
+	return out, rows.Err()
+}
+
+const bookingColumns = `id,user_id,candidate_email,start_at_utc,end_at_utc,status,COALESCE(source,''),COALESCE(type,''),
+	COALESCE(event_type_id::text,''),COALESCE(description,''),COALESCE(title,''),buffer_before_minutes,buffer_after_minutes,
+	COALESCE(external_provider,''),COALESCE(external_calendar_id,''),COALESCE(external_event_id,''),created_at,COALESCE(updated_at,created_at)`
+
+func scanBooking(row pgx.Row) (Booking, error) {
+	var b Booking
+	err := row.Scan(&b.ID, &b.UserID, &b.CandidateEmail, &b.StartAtUTC, &b.EndAtUTC, &b.Status,
+		&b.Source, &b.Type, &b.EventTypeID, &b.Description, &b.Title, &b.BufferBefore, &b.BufferAfter,
+		&b.ExternalProvider, &b.ExternalCalendarID, &b.ExternalEventID, &b.CreatedAt, &b.UpdatedAt)
+	return b, err
+}
+
+// GetBooking returns pgx.ErrNoRows when no booking in the tenant has the given id.
+func (a *App) GetBooking(ctx context.Context, id string) (Booking, error) {
+	return scanBooking(a.db(ctx).QueryRow(ctx, `SELECT `+bookingColumns+` FROM bookings
+	                                       WHERE id=$1 AND tenant_id=$2`, id, tenantArg(ctx)))
+}
+
+// getBookingForUpdate reads a booking and locks its row until tx ends.
+func getBookingForUpdate(ctx context.Context, tx pgx.Tx, id string) (Booking, error) {
+	return scanBooking(tx.QueryRow(ctx, `SELECT `+bookingColumns+` FROM bookings
+	                                     WHERE id=$1 AND tenant_id=$2 FOR UPDATE`, id, tenantArg(ctx)))
+}
+
+func insertBookingEvent(ctx context.Context, db querier, ev *BookingEvent) error {
+	q := `INSERT INTO booking_events
+          (id, booking_id, action, from_status, to_status, previous_start_at_utc, previous_end_at_utc,
+           start_at_utc, end_at_utc, reason, created_at)
+          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, now()) RETURNING id, created_at`
+	return db.QueryRow(ctx, q, ev.BookingID, ev.Action, nullIfEmpty(ev.FromStatus), ev.ToStatus,
+		ev.PreviousStartAtUTC, ev.PreviousEndAtUTC, ev.StartAtUTC, ev.EndAtUTC, nullIfEmpty(ev.Reason),
+	).Scan(&ev.ID, &ev.CreatedAt)
+}
+
+// ListBookingEvents returns a booking's history, oldest first.
+func (a *App) ListBookingEvents(ctx context.Context, bookingID string) ([]BookingEvent, error) {
+	q := `SELECT id,booking_id,action,COALESCE(from_status,''),to_status,previous_start_at_utc,previous_end_at_utc,
+	             start_at_utc,end_at_utc,COALESCE(reason,''),created_at
+	      FROM booking_events WHERE booking_id=$1 ORDER BY created_at, id`
+	rows, err := a.db(ctx).Query(ctx, q, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []BookingEvent
+	for rows.Next() {
+		var ev BookingEvent
+		if err := rows.Scan(&ev.ID, &ev.BookingID, &ev.Action, &ev.FromStatus, &ev.ToStatus,
+			&ev.PreviousStartAtUTC, &ev.PreviousEndAtUTC, &ev.StartAtUTC, &ev.EndAtUTC,
+			&ev.Reason, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// SetBookingExternalEvent records the calendar event mirroring a booking.
+func (a *App) SetBookingExternalEvent(ctx context.Context, bookingID string, ref ExternalEventRef) error {
+	_, err := a.db(ctx).Exec(ctx, `UPDATE bookings SET external_provider=$1, external_calendar_id=$2, external_event_id=$3
+	                          WHERE id=$4 AND tenant_id=$5`,
+		nullIfEmpty(ref.Provider), nullIfEmpty(ref.CalendarID), nullIfEmpty(ref.EventID), bookingID, tenantArg(ctx))
+	return err
+}
@@ -1,17 +1,23 @@
 package app
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 )
 
-// validateAvailabilityRule validates that start_time is before end_time
+// slugPattern matches URL-safe slugs such as the event type "60-min-technical"
+// or the user handle "jane-doe"
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validateAvailabilityRule validates that start_time is before end_time and that
+// timezone is a known IANA zone (defaulting to UTC when omitted)
 func validateAvailabilityRule(rule *AvailabilityRule) error {
 	startTime, err := time.Parse("15:04", rule.StartTime)
 	if err != nil {
@@ -27,11 +33,22 @@ func validateAvailabilityRule(rule *AvailabilityRule) error {
 		return fmt.Errorf("end_time (%s) must be after start_time (%s)", rule.EndTime, rule.StartTime)
 	}
 
+	if rule.SlotIncrement < 0 {
+		return fmt.Errorf("slot_increment_minutes must not be negative")
+	}
+
+	loc, err := loadTimezone(rule.Timezone)
+	if err != nil {
+		return err
+	}
+	rule.Timezone = loc.String()
+
 	return nil
 }
 
 // POST /users/:id/availability
 // Accepts list of rules (create/update by id if provided).
+// Rules without a timezone take the user's default.
 func (a *App) SetAvailabilityHandler(c *gin.Context) {
 	userID := c.Param("id")
 	var payload []AvailabilityRule
@@ -40,10 +57,22 @@ func (a *App) SetAvailabilityHandler(c *gin.Context) {
 		return
 	}
 	ctx := c.Request.Context()
+	tz, err := a.userTimezone(ctx, userID)
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	var savedRules []AvailabilityRule
 	for i := range payload {
 		payload[i].UserID = userID
+		if payload[i].Timezone == "" {
+			payload[i].Timezone = tz
+		}
 
 		// Validate the rule
 		if err := validateAvailabilityRule(&payload[i]); err != nil {
@@ -51,7 +80,12 @@ func (a *App) SetAvailabilityHandler(c *gin.Context) {
 			return
 		}
 
-		if err := a.InsertAvailabilityRule(ctx, &payload[i]); err != nil {
+		err := a.InsertAvailabilityRule(ctx, &payload[i])
+		if isForeignKeyViolation(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+			return
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -62,6 +96,7 @@ func (a *App) SetAvailabilityHandler(c *gin.Context) {
 }
 
 // PUT /users/:id/availability/:rule_id
+// A rule sent without a timezone takes the user's default, as on creation.
 func (a *App) UpdateAvailabilityHandler(c *gin.Context) {
 	userID := c.Param("id")
 	ruleID := c.Param("rule_id")
@@ -72,25 +107,38 @@ func (a *App) UpdateAvailabilityHandler(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+	if payload.Timezone == "" {
+		tz, err := a.userTimezone(ctx, userID)
+		if errors.Is(err, errUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		payload.Timezone = tz
+	}
+
 	// Validate the rule
 	if err := validateAvailabilityRule(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx := c.Request.Context()
 	now := time.Now().UTC()
 
 	q := `UPDATE availability_rules
-          SET start_time=$1, end_time=$2, slot_length_minutes=$3,
-              title=$4, available=$5, updated_at=$6
-          WHERE id=$7 AND user_id=$8
+          SET start_time=$1, end_time=$2, slot_length_minutes=$3, slot_increment_minutes=$4, timezone=$5,
+              title=$6, available=$7, updated_at=$8
+          WHERE id=$9 AND user_id=$10 AND tenant_id=$11
           RETURNING id`
 
 	var updatedID string
-	err := a.DB.QueryRow(ctx, q,
-		payload.StartTime, payload.EndTime, payload.SlotLengthMins,
-		payload.Title, payload.Available, now, ruleID, userID,
+	err := a.db(ctx).QueryRow(ctx, q,
+		payload.StartTime, payload.EndTime, payload.SlotLengthMins, nullIfZero(payload.SlotIncrement), payload.Timezone,
+		payload.Title, payload.Available, now, ruleID, userID, tenantArg(ctx),
 	).Scan(&updatedID)
 
 	if err == pgx.ErrNoRows {
@@ -120,7 +168,376 @@ func (a *App) ListAvailabilityHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, rules)
 }
 
-// GET /users/:id/slots?from=ISO&to=ISO
+// validateAvailabilityOverride validates the date and, for non-blocking overrides,
+// the replacement window; blocking overrides have their window cleared
+func validateAvailabilityOverride(o *AvailabilityOverride) error {
+	if _, err := time.Parse(dateLayout, o.Date); err != nil {
+		return fmt.Errorf("invalid date format: %s (expected YYYY-MM-DD)", o.Date)
+	}
+
+	loc, err := loadTimezone(o.Timezone)
+	if err != nil {
+		return err
+	}
+	o.Timezone = loc.String()
+
+	if o.Blocked {
+		o.StartTime, o.EndTime, o.SlotLengthMins, o.SlotIncrement = "", "", 0, 0
+		return nil
+	}
+
+	if o.SlotLengthMins <= 0 {
+		return fmt.Errorf("slot_length_minutes must be positive")
+	}
+	return validateAvailabilityRule(&AvailabilityRule{
+		StartTime:     o.StartTime,
+		EndTime:       o.EndTime,
+		SlotIncrement: o.SlotIncrement,
+		Timezone:      o.Timezone,
+	})
+}
+
+// POST /users/:id/availability/overrides
+// Accepts a list of date overrides; those without a timezone take the user's default.
+func (a *App) CreateAvailabilityOverridesHandler(c *gin.Context) {
+	userID := c.Param("id")
+	var payload []AvailabilityOverride
+	if err := c.BindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	tz, err := a.userTimezone(ctx, userID)
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range payload {
+		payload[i].UserID = userID
+		if payload[i].Timezone == "" {
+			payload[i].Timezone = tz
+		}
+		if err := validateAvailabilityOverride(&payload[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	for i := range payload {
+		err := a.InsertAvailabilityOverride(ctx, &payload[i])
+		if isForeignKeyViolation(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, payload)
+}
+
+// GET /users/:id/availability/overrides?from=YYYY-MM-DD&to=YYYY-MM-DD
+func (a *App) ListAvailabilityOverridesHandler(c *gin.Context) {
+	userID := c.Param("id")
+
+	var from, to time.Time
+	var err error
+	if s := c.Query("from"); s != "" {
+		if from, err = time.Parse(dateLayout, s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+	}
+	if s := c.Query("to"); s != "" {
+		if to, err = time.Parse(dateLayout, s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+	}
+
+	overrides, err := a.ListAvailabilityOverrides(c.Request.Context(), userID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, overrides)
+}
+
+// PUT /users/:id/availability/overrides/:override_id
+// An override sent without a timezone takes the user's default, as on creation.
+func (a *App) UpdateAvailabilityOverrideHandler(c *gin.Context) {
+	var payload AvailabilityOverride
+	if err := c.BindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payload.ID = c.Param("override_id")
+	payload.UserID = c.Param("id")
+
+	ctx := c.Request.Context()
+	if payload.Timezone == "" {
+		tz, err := a.userTimezone(ctx, payload.UserID)
+		if errors.Is(err, errUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		payload.Timezone = tz
+	}
+
+	if err := validateAvailabilityOverride(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := a.UpdateAvailabilityOverride(ctx, &payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "override not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payload)
+}
+
+// DELETE /users/:id/availability/overrides/:override_id
+func (a *App) DeleteAvailabilityOverrideHandler(c *gin.Context) {
+	deleted, err := a.DeleteAvailabilityOverride(c.Request.Context(), c.Param("id"), c.Param("override_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "override not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// validateUserSettings checks that buffers are within [0, maxBuffer], limits are not
+// negative and timezone is a known IANA zone (defaulting to UTC when omitted)
+func validateUserSettings(s *UserSettings) error {
+	maxMins := int(maxBuffer / time.Minute)
+	if s.BufferBefore < 0 || s.BufferBefore > maxMins {
+		return fmt.Errorf("buffer_before_minutes must be between 0 and %d", maxMins)
+	}
+	if s.BufferAfter < 0 || s.BufferAfter > maxMins {
+		return fmt.Errorf("buffer_after_minutes must be between 0 and %d", maxMins)
+	}
+	if s.MinNotice < 0 {
+		return fmt.Errorf("min_notice_minutes must not be negative")
+	}
+	if s.HorizonDays < 0 {
+		return fmt.Errorf("booking_horizon_days must not be negative")
+	}
+	if s.MaxPerDay < 0 || s.MaxPerWeek < 0 {
+		return fmt.Errorf("booking caps must not be negative")
+	}
+
+	loc, err := loadTimezone(s.Timezone)
+	if err != nil {
+		return err
+	}
+	s.Timezone = loc.String()
+
+	return nil
+}
+
+// GET /users/:id/settings
+func (a *App) GetUserSettingsHandler(c *gin.Context) {
+	settings, err := a.GetUserSettings(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// PUT /users/:id/settings
+func (a *App) UpdateUserSettingsHandler(c *gin.Context) {
+	var payload UserSettings
+	if err := c.BindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payload.UserID = c.Param("id")
+
+	if err := validateUserSettings(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := a.UpsertUserSettings(c.Request.Context(), &payload)
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payload)
+}
+
+// validateEventType checks the required fields and normalizes the slug
+func validateEventType(et *EventType) error {
+	et.Name = strings.TrimSpace(et.Name)
+	et.Slug = strings.ToLower(strings.TrimSpace(et.Slug))
+	if et.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !slugPattern.MatchString(et.Slug) {
+		return fmt.Errorf("slug must be lowercase letters, digits and dashes")
+	}
+	if et.DurationMins <= 0 {
+		return fmt.Errorf("duration_minutes must be positive")
+	}
+	maxMins := int(maxBuffer / time.Minute)
+	if et.BufferBefore != nil && (*et.BufferBefore < 0 || *et.BufferBefore > maxMins) {
+		return fmt.Errorf("buffer_before_minutes must be between 0 and %d", maxMins)
+	}
+	if et.BufferAfter != nil && (*et.BufferAfter < 0 || *et.BufferAfter > maxMins) {
+		return fmt.Errorf("buffer_after_minutes must be between 0 and %d", maxMins)
+	}
+
+	// drop duplicate rule ids so the ownership check counts them once
+	seen := map[string]bool{}
+	ruleIDs := et.RuleIDs[:0]
+	for _, id := range et.RuleIDs {
+		if !seen[id] {
+			seen[id] = true
+			ruleIDs = append(ruleIDs, id)
+		}
+	}
+	et.RuleIDs = ruleIDs
+	return nil
+}
+
+// POST /users/:id/event-types
+func (a *App) CreateEventTypeHandler(c *gin.Context) {
+	var payload EventType
+	if err := c.BindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payload.UserID = c.Param("id")
+
+	if err := validateEventType(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := a.InsertEventType(c.Request.Context(), &payload)
+	if errors.Is(err, errUnknownRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "slug already in use"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, payload)
+}
+
+// GET /users/:id/event-types
+func (a *App) ListEventTypesHandler(c *gin.Context) {
+	eventTypes, err := a.ListEventTypes(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, eventTypes)
+}
+
+// GET /users/:id/event-types/:event_type_id
+func (a *App) GetEventTypeHandler(c *gin.Context) {
+	et, err := a.GetEventType(c.Request.Context(), c.Param("id"), c.Param("event_type_id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event type not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, et)
+}
+
+// PUT /users/:id/event-types/:event_type_id
+func (a *App) UpdateEventTypeHandler(c *gin.Context) {
+	var payload EventType
+	if err := c.BindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payload.ID = c.Param("event_type_id")
+	payload.UserID = c.Param("id")
+
+	if err := validateEventType(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := a.UpdateEventType(c.Request.Context(), &payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event type not found"})
+		return
+	}
+	if errors.Is(err, errUnknownRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "slug already in use"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payload)
+}
+
+// DELETE /users/:id/event-types/:event_type_id
+func (a *App) DeleteEventTypeHandler(c *gin.Context) {
+	deleted, err := a.DeleteEventType(c.Request.Context(), c.Param("id"), c.Param("event_type_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event type not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// GET /users/:id/slots?from=ISO&to=ISO[&event_type_id=UUID]
 func (a *App) GetSlotsHandler(c *gin.Context) {
 	userID := c.Param("id")
 	fromStr := c.Query("from")
@@ -143,11 +560,31 @@ func (a *App) GetSlotsHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
 		return
 	}
-	slots, err := a.GenerateAvailableSlots(c.Request.Context(), userID, from.UTC(), to.UTC())
-	if err != nil {
+	if _, err := a.GetUser(c.Request.Context(), userID); errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	var opts SlotOptions
+	if id := c.Query("event_type_id"); id != "" {
+		et, err := a.GetEventType(c.Request.Context(), userID, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "event type not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		opts.EventType = &et
+	}
+	slots, err := a.GenerateAvailableSlots(c.Request.Context(), userID, from.UTC(), to.UTC(), opts)
+	if err != nil {
+		respondSlotError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, slots)
 }
 
@@ -155,7 +592,8 @@ type createBookingReq struct {
 	UserID         string `json:"user_id"` // optional, since it's in URL path
 	CandidateEmail string `json:"candidate_email" binding:"required,email"`
 	StartAtUTCStr  string `json:"start_at_utc" binding:"required"` // RFC3339
-	EndAtUTCStr    string `json:"end_at_utc" binding:"required"`
+	EndAtUTCStr    string `json:"end_at_utc"`                      // optional with event_type_id
+	EventTypeID    string `json:"event_type_id,omitempty"`
 	Source         string `json:"source,omitempty"`
 	Type           string `json:"type,omitempty"`
 	Description    string `json:"description,omitempty"`
@@ -216,61 +654,91 @@ func (a *App) CreateBookingHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_at_utc"})
 		return
 	}
-	end, err := time.Parse(time.RFC3339, req.EndAtUTCStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_at_utc"})
+
+	ctx := c.Request.Context()
+
+	// with an event type the duration is fixed, so end_at_utc may be omitted
+	var eventType *EventType
+	if req.EventTypeID != "" {
+		et, err := a.GetEventType(ctx, userID, req.EventTypeID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "event type not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		eventType = &et
+		if req.Type == "" {
+			req.Type = et.Slug
+		}
+	}
+
+	var end time.Time
+	switch {
+	case req.EndAtUTCStr != "":
+		end, err = time.Parse(time.RFC3339, req.EndAtUTCStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_at_utc"})
+			return
+		}
+	case eventType != nil:
+		end = start.Add(time.Duration(eventType.DurationMins) * time.Minute)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at_utc required without event_type_id"})
 		return
 	}
 	if !start.Before(end) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be before end"})
 		return
 	}
-
-	ctx := context.Background()
-	tx, err := a.DB.Begin(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if eventType != nil && end.Sub(start) != time.Duration(eventType.DurationMins)*time.Minute {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("event type lasts %d minutes", eventType.DurationMins)})
 		return
 	}
-	defer tx.Rollback(ctx)
 
-	// check overlapping confirmed booking
-	checkQ := `SELECT id FROM bookings 
-			   WHERE user_id=$1 AND status='confirmed' 
-			   AND start_at_utc = $2 FOR UPDATE`
-	var existingID string
-	err = tx.QueryRow(ctx, checkQ, userID, start.UTC()).Scan(&existingID)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+	settings, err := a.GetUserSettings(ctx, userID)
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	if existingID != "" {
-		c.JSON(http.StatusConflict, gin.H{"error": "slot already booked"})
+	if code, msg := settings.checkBookingWindow(time.Now().UTC(), start.UTC()); code != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "code": code})
 		return
 	}
 
-	// verify slot belongs to user's availability
-	slots, err := a.GenerateAvailableSlots(ctx, userID, start.Add(-1*time.Second).UTC(), end.Add(1*time.Second).UTC())
+	tx, err := a.beginTenantTx(ctx)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ok := false
-	for _, s := range slots {
-		if s.StartUTC.Equal(start.UTC()) && s.EndUTC.Equal(end.UTC()) {
-			ok = true
-			break
-		}
+	defer tx.Rollback(ctx)
+
+	// serialize bookings for this user so the buffer and cap checks below cannot race
+	if err := lockUserBookings(ctx, tx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "slot not available"})
+
+	if bookErr, err := a.checkSlotBookable(ctx, tx, userID, start.UTC(), end.UTC(), settings, eventType, ""); err != nil {
+		respondSlotError(c, err)
+		return
+	} else if bookErr != nil {
+		bookErr.respond(c)
 		return
 	}
+	before, after := eventType.buffers(settings)
 
 	// insert booking
 	insertQ := `INSERT INTO bookings 
-		(id, user_id, candidate_email, start_at_utc, end_at_utc, status, source, type, description, title, created_at)
-		VALUES (gen_random_uuid(), $1, $2, $3, $4, 'confirmed', $5, $6, $7, $8, now())
+		(id, user_id, candidate_email, start_at_utc, end_at_utc, status, source, type, description, title,
+		 event_type_id, buffer_before_minutes, buffer_after_minutes, tenant_id, created_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, 'confirmed', $5, $6, $7, $8, $9, $10, $11, $12, now())
 		RETURNING id`
 	var newID string
 	err = tx.QueryRow(
@@ -283,17 +751,48 @@ func (a *App) CreateBookingHandler(c *gin.Context) {
 		req.Type,
 		req.Description,
 		req.Title,
+		nullIfEmpty(req.EventTypeID),
+		int(before/time.Minute),
+		int(after/time.Minute),
+		tenantArg(ctx),
 	).Scan(&newID)
+	if isExclusionViolation(err) {
+		// a concurrent request won the race for an overlapping slot
+		c.JSON(http.StatusConflict, gin.H{"error": "slot already booked"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := insertBookingEvent(ctx, tx, &BookingEvent{
+		BookingID:  newID,
+		Action:     bookingActionCreated,
+		ToStatus:   bookingStatusConfirmed,
+		StartAtUTC: start.UTC(),
+		EndAtUTC:   end.UTC(),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// mirror onto the host's calendar once the booking is committed
+	externalEventID := a.syncCreatedBooking(ctx, Booking{
+		ID:             newID,
+		UserID:         userID,
+		CandidateEmail: req.CandidateEmail,
+		StartAtUTC:     start.UTC(),
+		EndAtUTC:       end.UTC(),
+		Description:    req.Description,
+		Title:          req.Title,
+	}, eventType)
+
 	// Create response with proper field handling
 	response := gin.H{
 		"id":              newID,
@@ -312,6 +811,12 @@ func (a *App) CreateBookingHandler(c *gin.Context) {
 	if req.Type != "" {
 		response["type"] = req.Type
 	}
+	if req.EventTypeID != "" {
+		response["event_type_id"] = req.EventTypeID
+	}
+	if externalEventID != "" {
+		response["external_event_id"] = externalEventID
+	}
 	if req.Description != "" {
 		response["description"] = req.Description
 	}
@@ -322,15 +827,207 @@ func (a *App) CreateBookingHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// GET /bookings/:id
+// Returns the booking together with its status history.
+func (a *App) GetBookingHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	b, err := a.GetBooking(ctx, c.Param("id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !a.authorizeUser(c, b.UserID) {
+		return
+	}
+	if b.History, err = a.ListBookingEvents(ctx, b.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+type rescheduleBookingReq struct {
+	StartAtUTCStr string `json:"start_at_utc" binding:"required"` // RFC3339
+	EndAtUTCStr   string `json:"end_at_utc"`                      // optional, keeps the current duration
+	Reason        string `json:"reason,omitempty"`
+}
+
+// POST /bookings/:id/reschedule
+// Moves a confirmed booking to a new slot in one transaction, so the old slot
+// is only released once the new one is secured.
+func (a *App) RescheduleBookingHandler(c *gin.Context) {
+	id := c.Param("id")
+	var req rescheduleBookingReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, err := time.Parse(time.RFC3339, req.StartAtUTCStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_at_utc"})
+		return
+	}
+	var end time.Time
+	if req.EndAtUTCStr != "" {
+		if end, err = time.Parse(time.RFC3339, req.EndAtUTCStr); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_at_utc"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+
+	// look up the host first: the per-user lock must be taken before the row lock,
+	// in the same order CreateBookingHandler uses
+	current, err := a.GetBooking(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !a.authorizeUser(c, current.UserID) {
+		return
+	}
+	userID := current.UserID
+
+	tx, err := a.beginTenantTx(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUserBookings(ctx, tx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	current, err = getBookingForUpdate(ctx, tx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// deleted since the lookup above
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current.Status != bookingStatusConfirmed {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot reschedule a %s booking", current.Status)})
+		return
+	}
+
+	if end.IsZero() {
+		end = start.Add(current.EndAtUTC.Sub(current.StartAtUTC))
+	}
+	if !start.Before(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be before end"})
+		return
+	}
+
+	var eventType *EventType
+	if current.EventTypeID != "" {
+		et, err := a.GetEventType(ctx, userID, current.EventTypeID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the booking's event type no longer exists"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		eventType = &et
+		if end.Sub(start) != time.Duration(et.DurationMins)*time.Minute {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("event type lasts %d minutes", et.DurationMins)})
+			return
+		}
+	}
+
+	settings, err := a.GetUserSettings(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if code, msg := settings.checkBookingWindow(time.Now().UTC(), start.UTC()); code != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "code": code})
+		return
+	}
+	if bookErr, err := a.checkSlotBookable(ctx, tx, userID, start.UTC(), end.UTC(), settings, eventType, id); err != nil {
+		respondSlotError(c, err)
+		return
+	} else if bookErr != nil {
+		bookErr.respond(c)
+		return
+	}
+	before, after := eventType.buffers(settings)
+
+	updateQ := `UPDATE bookings
+	            SET start_at_utc=$1, end_at_utc=$2, buffer_before_minutes=$3, buffer_after_minutes=$4, updated_at=now()
+	            WHERE id=$5 AND tenant_id=$6`
+	_, err = tx.Exec(ctx, updateQ, start.UTC(), end.UTC(), int(before/time.Minute), int(after/time.Minute), id, tenantArg(ctx))
+	if isExclusionViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "slot already booked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	prevStart, prevEnd := current.StartAtUTC, current.EndAtUTC
+	if err := insertBookingEvent(ctx, tx, &BookingEvent{
+		BookingID:          id,
+		Action:             bookingActionRescheduled,
+		FromStatus:         current.Status,
+		ToStatus:           current.Status,
+		PreviousStartAtUTC: &prevStart,
+		PreviousEndAtUTC:   &prevEnd,
+		StartAtUTC:         start.UTC(),
+		EndAtUTC:           end.UTC(),
+		Reason:             req.Reason,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := a.GetBooking(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	a.syncRescheduledBooking(ctx, updated)
+	if updated.History, err = a.ListBookingEvents(ctx, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
 // DELETE /bookings/:id
 func (a *App) CancelBookingHandler(c *gin.Context) {
 	id := c.Param("id")
 	ctx := c.Request.Context()
 
+	tx, err := a.beginTenantTx(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback(ctx)
+
 	// First check if the booking exists and get its current status
-	checkQ := `SELECT status FROM bookings WHERE id=$1`
-	var currentStatus string
-	err := a.DB.QueryRow(ctx, checkQ, id).Scan(&currentStatus)
+	current, err := getBookingForUpdate(ctx, tx, id)
 	if err == pgx.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
 		return
@@ -340,15 +1037,20 @@ func (a *App) CancelBookingHandler(c *gin.Context) {
 		return
 	}
 
+	if !a.authorizeUser(c, current.UserID) {
+		return
+	}
+
 	// Check if already cancelled
-	if currentStatus == "cancelled" {
+	if current.Status == bookingStatusCancelled {
 		c.JSON(http.StatusConflict, gin.H{"error": "booking not found"})
 		return
 	}
 
 	// Update to cancelled
-	updateQ := `UPDATE bookings SET status='cancelled' WHERE id=$1 AND status != 'cancelled'`
-	res, err := a.DB.Exec(ctx, updateQ, id)
+	updateQ := `UPDATE bookings SET status='cancelled', updated_at=now()
+	            WHERE id=$1 AND status != 'cancelled' AND tenant_id=$2`
+	res, err := tx.Exec(ctx, updateQ, id, tenantArg(ctx))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -359,5 +1061,23 @@ func (a *App) CancelBookingHandler(c *gin.Context) {
 		return
 	}
 
+	if err := insertBookingEvent(ctx, tx, &BookingEvent{
+		BookingID:  id,
+		Action:     bookingActionCancelled,
+		FromStatus: current.Status,
+		ToStatus:   bookingStatusCancelled,
+		StartAtUTC: current.StartAtUTC,
+		EndAtUTC:   current.EndAtUTC,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	a.syncCancelledBooking(ctx, current)
+
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
@@ -0,0 +1,372 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icalProperty is one content line of an iCalendar object (RFC 5545).
+// Parameter names are upper-cased; Value is kept as written, so TEXT values
+// are still escaped.
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icalComponent is a BEGIN/END block such as VCALENDAR or VEVENT.
+type icalComponent struct {
+	Name       string
+	Props      []icalProperty
+	Components []*icalComponent
+}
+
+// parseICalendar parses iCalendar data into its top-level components.
+func parseICalendar(data string) ([]*icalComponent, error) {
+	var (
+		roots []*icalComponent
+		stack []*icalComponent
+	)
+	for _, line := range unfoldICalLines(data) {
+		if line == "" {
+			continue
+		}
+		p, err := parseICalLine(line)
+		if err != nil {
+			return nil, err
+		}
+		switch p.Name {
+		case "BEGIN":
+			comp := &icalComponent{Name: strings.ToUpper(p.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, comp)
+			} else {
+				roots = append(roots, comp)
+			}
+			stack = append(stack, comp)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("ical: unexpected END:%s", p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("ical: property %s outside a component", p.Name)
+			}
+			cur := stack[len(stack)-1]
+			cur.Props = append(cur.Props, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("ical: unterminated %s", stack[len(stack)-1].Name)
+	}
+	return roots, nil
+}
+
+// unfoldICalLines splits data into logical lines, joining folded continuations.
+func unfoldICalLines(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	var out []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(out) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			out[len(out)-1] += line[1:]
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// parseICalLine parses NAME;PARAM=value;PARAM="quoted":value.
+func parseICalLine(line string) (icalProperty, error) {
+	p := icalProperty{}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, fmt.Errorf("ical: malformed line %q", line)
+	}
+	p.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		i++
+		eq := strings.IndexByte(line[i:], '=')
+		if eq < 0 {
+			return p, fmt.Errorf("ical: malformed parameter in %q", line)
+		}
+		name := strings.ToUpper(line[i : i+eq])
+		i += eq + 1
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			end := strings.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("ical: unterminated quote in %q", line)
+			}
+			value = line[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			end := strings.IndexAny(line[i:], ";:")
+			if end < 0 {
+				return p, fmt.Errorf("ical: malformed parameter in %q", line)
+			}
+			value = line[i : i+end]
+			i += end
+		}
+		if p.Params == nil {
+			p.Params = make(map[string]string)
+		}
+		p.Params[name] = value
+		if i >= len(line) {
+			return p, fmt.Errorf("ical: missing value in %q", line)
+		}
+	}
+	p.Value = line[i+1:]
+	return p, nil
+}
+
+// get returns the first property called name, or nil.
+func (c *icalComponent) get(name string) *icalProperty {
+	for i := range c.Props {
+		if c.Props[i].Name == name {
+			return &c.Props[i]
+		}
+	}
+	return nil
+}
+
+// all returns every property called name.
+func (c *icalComponent) all(name string) []icalProperty {
+	var out []icalProperty
+	for _, p := range c.Props {
+		if p.Name == name {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// text returns the unescaped value of a TEXT property, or "".
+func (c *icalComponent) text(name string) string {
+	if p := c.get(name); p != nil {
+		return unescapeICalText(p.Value)
+	}
+	return ""
+}
+
+// set replaces every property called name with a single one.
+func (c *icalComponent) set(name, value string, params map[string]string) {
+	c.remove(name)
+	c.Props = append(c.Props, icalProperty{Name: name, Params: params, Value: value})
+}
+
+// remove drops every property called name.
+func (c *icalComponent) remove(name string) {
+	kept := c.Props[:0]
+	for _, p := range c.Props {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	c.Props = kept
+}
+
+// children returns the direct subcomponents called name.
+func (c *icalComponent) children(name string) []*icalComponent {
+	var out []*icalComponent
+	for _, sub := range c.Components {
+		if sub.Name == name {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// encode serializes c with CRLF line endings, folding lines at 75 octets.
+func (c *icalComponent) encode() string {
+	var b strings.Builder
+	c.encodeTo(&b)
+	return b.String()
+}
+
+func (c *icalComponent) encodeTo(b *strings.Builder) {
+	writeICalLine(b, "BEGIN:"+c.Name)
+	for _, p := range c.Props {
+		var line strings.Builder
+		line.WriteString(p.Name)
+		names := make([]string, 0, len(p.Params))
+		for name := range p.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := p.Params[name]
+			line.WriteString(";" + name + "=")
+			if strings.ContainsAny(value, ";:,") {
+				value = `"` + value + `"`
+			}
+			line.WriteString(value)
+		}
+		line.WriteString(":" + p.Value)
+		writeICalLine(b, line.String())
+	}
+	for _, sub := range c.Components {
+		sub.encodeTo(b)
+	}
+	writeICalLine(b, "END:"+c.Name)
+}
+
+// writeICalLine writes one content line, folding it without splitting UTF-8 sequences.
+func writeICalLine(b *strings.Builder, line string) {
+	const limit = 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// escapeICalText escapes a TEXT value.
+func escapeICalText(s string) string {
+	return icalTextEscaper.Replace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+var icalTextUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// unescapeICalText reverses escapeICalText.
+func unescapeICalText(s string) string {
+	return icalTextUnescaper.Replace(s)
+}
+
+// icalUTCLayout is the iCalendar UTC date-time form.
+const icalUTCLayout = "20060102T150405Z"
+
+// formatICalUTC formats t as an iCalendar UTC date-time.
+func formatICalUTC(t time.Time) string {
+	return t.UTC().Format(icalUTCLayout)
+}
+
+// parseICalTime parses a DATE or DATE-TIME property. UTC values end in Z,
+// TZID names the zone of local values, and floating values and dates use loc.
+// A TZID outside the tz database (e.g. a Windows zone name) also falls back to loc.
+func parseICalTime(p *icalProperty, loc *time.Location) (t time.Time, allDay bool, err error) {
+	value := strings.TrimSpace(p.Value)
+	if p.Params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		date, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		return wallClock(date, time.Time{}, loc), true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalUTCLayout, value)
+		return t, false, err
+	}
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if tz, err := loadTimezone(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = tz
+		}
+	}
+	naive, err := time.Parse("20060102T150405", value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	t = wallClock(naive, naive, loc).Add(time.Duration(naive.Second()) * time.Second)
+	return t, false, nil
+}
+
+// parseICalDuration parses an RFC 5545 duration such as PT1H30M or -P1D.
+func parseICalDuration(s string) (time.Duration, error) {
+	orig := s
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("ical: invalid duration %q", orig)
+	}
+	s = s[1:]
+
+	var (
+		total  time.Duration
+		inTime bool
+		num    string
+	)
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("ical: invalid duration %q", orig)
+		}
+		num = ""
+		switch {
+		case r == 'W' && !inTime:
+			total += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			total += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			total += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			total += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			total += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("ical: invalid duration %q", orig)
+		}
+	}
+	if num != "" {
+		return 0, fmt.Errorf("ical: invalid duration %q", orig)
+	}
+	return sign * total, nil
+}
+
+// icalEventSpan returns the start and end of a VEVENT, resolving local times in loc.
+// Without DTEND or DURATION an all-day event lasts one day and a timed event is instantaneous.
+func icalEventSpan(ev *icalComponent, loc *time.Location) (start, end time.Time, allDay bool, err error) {
+	dtstart := ev.get("DTSTART")
+	if dtstart == nil {
+		return time.Time{}, time.Time{}, false, errors.New("ical: event has no DTSTART")
+	}
+	if start, allDay, err = parseICalTime(dtstart, loc); err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+
+	switch {
+	case ev.get("DTEND") != nil:
+		end, _, err = parseICalTime(ev.get("DTEND"), loc)
+	case ev.get("DURATION") != nil:
+		var d time.Duration
+		d, err = parseICalDuration(ev.get("DURATION").Value)
+		end = start.Add(d)
+	case allDay:
+		y, m, d := start.In(loc).Date()
+		end = wallClock(time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC), time.Time{}, loc)
+	default:
+		end = start
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, false, err
+	}
+	return start.UTC(), end.UTC(), allDay, nil
+}
@@ -0,0 +1,442 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRulePeriods bounds recurrence expansion, so a rule whose filters never
+// match (BYMONTH=2;BYMONTHDAY=30) can't loop forever.
+const maxRulePeriods = 100000
+
+// icalRule is a parsed RRULE. BYHOUR, BYMINUTE, BYSECOND, BYWEEKNO and
+// BYYEARDAY are not supported; occurrences keep DTSTART's time of day.
+type icalRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time // zero when unbounded
+	ByDay      []icalWeekday
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+// icalWeekday is a BYDAY entry such as MO, 2TU or -1FR. N is 0 for every such weekday.
+type icalWeekday struct {
+	N   int
+	Day time.Weekday
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseICalRule parses an RRULE value; a floating UNTIL is read in loc.
+func parseICalRule(value string, loc *time.Location) (*icalRule, error) {
+	r := &icalRule{Interval: 1, WeekStart: time.Monday}
+	ints := func(v string) ([]int, error) {
+		var out []int
+		for _, part := range strings.Split(v, ",") {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, n)
+		}
+		return out, nil
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		name, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("ical: malformed RRULE part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(v)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(v)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(v)
+		case "UNTIL":
+			r.Until, _, err = parseICalTime(&icalProperty{Value: v}, loc)
+		case "BYDAY":
+			for _, d := range strings.Split(strings.ToUpper(v), ",") {
+				if len(d) < 2 {
+					return nil, fmt.Errorf("ical: invalid BYDAY %q", d)
+				}
+				wd, ok := icalWeekdays[d[len(d)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("ical: invalid BYDAY %q", d)
+				}
+				n := 0
+				if prefix := d[:len(d)-2]; prefix != "" {
+					if n, err = strconv.Atoi(prefix); err != nil {
+						return nil, fmt.Errorf("ical: invalid BYDAY %q", d)
+					}
+				}
+				r.ByDay = append(r.ByDay, icalWeekday{N: n, Day: wd})
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = ints(v)
+		case "BYMONTH":
+			r.ByMonth, err = ints(v)
+		case "BYSETPOS":
+			r.BySetPos, err = ints(v)
+		case "WKST":
+			wd, ok := icalWeekdays[strings.ToUpper(v)]
+			if !ok {
+				return nil, fmt.Errorf("ical: invalid WKST %q", v)
+			}
+			r.WeekStart = wd
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ical: invalid RRULE %s: %w", name, err)
+		}
+	}
+
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("ical: unsupported FREQ %q", r.Freq)
+	}
+	if r.Interval < 1 {
+		return nil, fmt.Errorf("ical: invalid INTERVAL %d", r.Interval)
+	}
+	return r, nil
+}
+
+// dates calls yield with each occurrence date (a UTC midnight) on or after
+// start, in order, until yield returns false. start itself always comes first.
+// COUNT and UNTIL are left to the caller, which knows the time of day.
+func (r *icalRule) dates(start time.Time, yield func(time.Time) bool) {
+	if !yield(start) {
+		return
+	}
+	for period := 0; period < maxRulePeriods; period++ {
+		for _, d := range r.applySetPos(r.candidates(start, period)) {
+			if !d.After(start) {
+				continue
+			}
+			if !yield(d) {
+				return
+			}
+		}
+	}
+}
+
+// candidates returns the sorted dates of the period'th period after start.
+func (r *icalRule) candidates(start time.Time, period int) []time.Time {
+	step := period * r.Interval
+	var out []time.Time
+
+	switch r.Freq {
+	case "DAILY":
+		d := start.AddDate(0, 0, step)
+		if r.matchesMonth(d) && r.matchesMonthDay(d) && r.matchesWeekday(d) {
+			out = append(out, d)
+		}
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := start.AddDate(0, 0, -offset+7*step)
+		for i := 0; i < 7; i++ {
+			d := weekStart.AddDate(0, 0, i)
+			if !r.matchesMonth(d) {
+				continue
+			}
+			if len(r.ByDay) == 0 && d.Weekday() != start.Weekday() {
+				continue
+			}
+			if len(r.ByDay) > 0 && !r.matchesWeekday(d) {
+				continue
+			}
+			out = append(out, d)
+		}
+	case "MONTHLY":
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchesMonth(first) {
+			out = r.monthDays(first, start)
+		}
+	case "YEARLY":
+		year := start.Year() + step
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, m := range r.ByMonth {
+				out = append(out, r.monthDays(time.Date(year, time.Month(m), 1, 0, 0, 0, 0, time.UTC), start)...)
+			}
+		case len(r.ByDay) > 0:
+			out = weekdaysIn(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC), r.ByDay)
+		case len(r.ByMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				out = append(out, r.monthDays(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC), start)...)
+			}
+		default:
+			d := time.Date(year, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			if d.Month() == start.Month() { // skip Feb 29 in common years
+				out = append(out, d)
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupeDates(out)
+}
+
+// monthDays returns the days of the month starting at first selected by
+// BYMONTHDAY and BYDAY, or start's day of month when neither is set.
+func (r *icalRule) monthDays(first, start time.Time) []time.Time {
+	next := first.AddDate(0, 1, 0)
+	last := next.AddDate(0, 0, -1).Day()
+
+	var out []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, n := range r.ByMonthDay {
+			if n < 0 {
+				n = last + n + 1
+			}
+			if n < 1 || n > last {
+				continue
+			}
+			d := first.AddDate(0, 0, n-1)
+			// BYDAY further limits BYMONTHDAY
+			if len(r.ByDay) == 0 || r.matchesWeekday(d) {
+				out = append(out, d)
+			}
+		}
+	case len(r.ByDay) > 0:
+		out = weekdaysIn(first, next, r.ByDay)
+	default:
+		if start.Day() <= last {
+			out = append(out, first.AddDate(0, 0, start.Day()-1))
+		}
+	}
+	return out
+}
+
+// weekdaysIn returns the days in [from, to) matching days, where an ordinal
+// counts occurrences of the weekday within the range (negative from the end).
+func weekdaysIn(from, to time.Time, days []icalWeekday) []time.Time {
+	var out []time.Time
+	for _, wd := range days {
+		var matches []time.Time
+		for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+			if d.Weekday() == wd.Day {
+				matches = append(matches, d)
+			}
+		}
+		switch {
+		case wd.N == 0:
+			out = append(out, matches...)
+		case wd.N > 0 && wd.N <= len(matches):
+			out = append(out, matches[wd.N-1])
+		case wd.N < 0 && -wd.N <= len(matches):
+			out = append(out, matches[len(matches)+wd.N])
+		}
+	}
+	return out
+}
+
+func (r *icalRule) matchesMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == d.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *icalRule) matchesMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, n := range r.ByMonthDay {
+		if n == d.Day() || (n < 0 && last+n+1 == d.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday checks BYDAY ignoring ordinals, as used by DAILY and WEEKLY rules.
+func (r *icalRule) matchesWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// applySetPos keeps the BYSETPOS-selected entries of one period's candidates.
+func (r *icalRule) applySetPos(dates []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 {
+		return dates
+	}
+	var out []time.Time
+	for _, pos := range r.BySetPos {
+		switch {
+		case pos > 0 && pos <= len(dates):
+			out = append(out, dates[pos-1])
+		case pos < 0 && -pos <= len(dates):
+			out = append(out, dates[len(dates)+pos])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return dedupeDates(out)
+}
+
+func dedupeDates(sorted []time.Time) []time.Time {
+	out := sorted[:0]
+	for i, d := range sorted {
+		if i == 0 || !d.Equal(sorted[i-1]) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// icalTimeLocation is the zone a DATE-TIME property is expressed in: UTC for
+// Z values, its TZID when known, and loc otherwise.
+func icalTimeLocation(p *icalProperty, loc *time.Location) *time.Location {
+	if strings.HasSuffix(strings.TrimSpace(p.Value), "Z") {
+		return time.UTC
+	}
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if tz, err := loadTimezone(strings.TrimPrefix(tzid, "/")); err == nil {
+			return tz
+		}
+	}
+	return loc
+}
+
+// icalTimeList parses a multi-valued EXDATE or RDATE property.
+func icalTimeList(p icalProperty, loc *time.Location) ([]time.Time, error) {
+	var out []time.Time
+	for _, v := range strings.Split(p.Value, ",") {
+		t, _, err := parseICalTime(&icalProperty{Name: p.Name, Params: p.Params, Value: v}, loc)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t.UTC())
+	}
+	return out, nil
+}
+
+// icalOccurrences expands a VEVENT into the occurrences overlapping [from, to),
+// applying RRULE, RDATE and EXDATE as well as skip, the original start times of
+// instances overridden elsewhere by RECURRENCE-ID. Recurring times keep their
+// local wall-clock time across DST changes.
+func icalOccurrences(ev *icalComponent, loc *time.Location, from, to time.Time, skip map[int64]bool) ([]interval, error) {
+	start, end, allDay, err := icalEventSpan(ev, loc)
+	if err != nil {
+		return nil, err
+	}
+	rrule := ev.get("RRULE")
+	if rrule == nil && ev.get("RDATE") == nil {
+		span := interval{Start: start, End: end}
+		if span.End.After(from) && span.Start.Before(to) && !skip[start.Unix()] {
+			return []interval{span}, nil
+		}
+		return nil, nil
+	}
+
+	evLoc := icalTimeLocation(ev.get("DTSTART"), loc)
+	if allDay {
+		evLoc = loc
+	}
+	local := start.In(evLoc)
+	tod := time.Date(0, 1, 1, local.Hour(), local.Minute(), 0, 0, time.UTC)
+	secs := time.Duration(local.Second()) * time.Second
+	duration := end.Sub(start)
+	days := int((duration + 12*time.Hour) / (24 * time.Hour))
+
+	// occurrence builds the span for a local date
+	occurrence := func(date time.Time) interval {
+		if allDay {
+			return interval{
+				Start: wallClock(date, time.Time{}, evLoc).UTC(),
+				End:   wallClock(date.AddDate(0, 0, days), time.Time{}, evLoc).UTC(),
+			}
+		}
+		s := wallClock(date, tod, evLoc).Add(secs).UTC()
+		return interval{Start: s, End: s.Add(duration)}
+	}
+
+	excluded := make(map[int64]bool, len(skip))
+	for k := range skip {
+		excluded[k] = true
+	}
+	for _, p := range ev.all("EXDATE") {
+		times, err := icalTimeList(p, evLoc)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range times {
+			excluded[t.Unix()] = true
+		}
+	}
+
+	var out []interval
+	add := func(span interval) {
+		if !excluded[span.Start.Unix()] && span.End.After(from) && span.Start.Before(to) {
+			out = append(out, span)
+		}
+	}
+
+	if rrule != nil {
+		rule, err := parseICalRule(rrule.Value, evLoc)
+		if err != nil {
+			return nil, err
+		}
+		y, m, d := local.Date()
+		count := 0
+		rule.dates(time.Date(y, m, d, 0, 0, 0, 0, time.UTC), func(date time.Time) bool {
+			span := occurrence(date)
+			if !rule.Until.IsZero() && span.Start.After(rule.Until) {
+				return false
+			}
+			if !span.Start.Before(to) {
+				return false
+			}
+			count++
+			if rule.Count > 0 && count > rule.Count {
+				return false
+			}
+			add(span)
+			return true
+		})
+	} else {
+		add(interval{Start: start, End: end})
+	}
+
+	for _, p := range ev.all("RDATE") {
+		if p.Params["VALUE"] == "PERIOD" {
+			continue
+		}
+		times, err := icalTimeList(p, evLoc)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range times {
+			add(interval{Start: t, End: t.Add(duration)})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
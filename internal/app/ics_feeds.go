@@ -0,0 +1,539 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// icsRefreshInterval is how often each feed is polled.
+	icsRefreshInterval = 15 * time.Minute
+	// icsReexpandAfter forces an unconditional fetch so the expansion window
+	// keeps moving forward even when the feed never changes.
+	icsReexpandAfter = 24 * time.Hour
+	// icsLookback and icsHorizon bound the busy intervals kept per feed.
+	icsLookback = 24 * time.Hour
+	icsHorizon  = 400 * 24 * time.Hour
+	// icsMaxBytes caps the size of a feed download.
+	icsMaxBytes = 10 << 20
+)
+
+// icsFeedProvider scopes sealed feed URLs in the token cipher's AAD.
+const icsFeedProvider = "ics"
+
+// errICSFeedNotFound is returned for a feed that does not exist or belongs to another user.
+var errICSFeedNotFound = errors.New("ics feed not found")
+
+// ICSFeed is a subscribed ICS URL whose events block the user's availability.
+// The URL itself may be a secret and is never returned; only its host is.
+type ICSFeed struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	Name          string     `json:"name,omitempty"`
+	Host          string     `json:"url_host"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at,omitempty"`
+
+	url          string
+	etag         string
+	lastModified string
+}
+
+// allowPrivateHosts reports whether ICS_ALLOW_PRIVATE_HOSTS lets ICS feeds and
+// CalDAV servers be loopback or private addresses, e.g. a local server in
+// tests. Otherwise they are refused so user-supplied URLs can't be used to
+// probe the internal network.
+func allowPrivateHosts() bool {
+	allowed, _ := strconv.ParseBool(os.Getenv("ICS_ALLOW_PRIVATE_HOSTS"))
+	return allowed
+}
+
+// parseICSFeedURL validates a feed address, mapping webcal:// to https://.
+func parseICSFeedURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return nil, errors.New("invalid feed url")
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	case "http", "https":
+		u.Scheme = strings.ToLower(u.Scheme)
+	default:
+		return nil, errors.New("feed url must use http, https or webcal")
+	}
+	if u.User != nil {
+		return nil, errors.New("feed url must not contain credentials")
+	}
+	return u, nil
+}
+
+// publicHTTPClient is for requests to user-supplied URLs. It refuses
+// connections to non-public addresses (after DNS resolution and on every
+// redirect) unless allowPrivateHosts; tag prefixes the refusal.
+func publicHTTPClient(tag string) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivateHosts() {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+				return fmt.Errorf("%s: refusing to connect to %s", tag, host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{Timeout: providerHTTPTimeout, Transport: transport}
+}
+
+// icsFetchResult is a downloaded feed, or notModified when the cached copy is current.
+type icsFetchResult struct {
+	notModified  bool
+	body         string
+	etag         string
+	lastModified string
+}
+
+// fetchICSFeed downloads feedURL, sending etag and lastModified as validators when set.
+func fetchICSFeed(ctx context.Context, feedURL, etag, lastModified string) (*icsFetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar, */*;q=0.1")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := publicHTTPClient("ics").Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return &icsFetchResult{notModified: true, etag: etag, lastModified: lastModified}, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("ics: feed returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, icsMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > icsMaxBytes {
+		return nil, fmt.Errorf("ics: feed larger than %d bytes", icsMaxBytes)
+	}
+	return &icsFetchResult{
+		body:         string(body),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// icsBusyIntervals expands the events of an ICS document into merged busy
+// intervals overlapping [from, to). Floating times use the calendar's
+// X-WR-TIMEZONE, else loc. Transparent and cancelled events are free time, a
+// RECURRENCE-ID instance replaces the occurrence it overrides, and events that
+// fail to parse are skipped and counted.
+func icsBusyIntervals(data string, loc *time.Location, from, to time.Time) (busy []interval, skipped int, err error) {
+	roots, err := parseICalendar(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var calendars []*icalComponent
+	for _, root := range roots {
+		if root.Name == "VCALENDAR" {
+			calendars = append(calendars, root)
+		}
+	}
+	if len(calendars) == 0 {
+		return nil, 0, errors.New("ics: no VCALENDAR in feed")
+	}
+
+	for _, cal := range calendars {
+		calLoc := loc
+		if p := cal.get("X-WR-TIMEZONE"); p != nil {
+			if tz, err := loadTimezone(strings.TrimSpace(p.Value)); err == nil {
+				calLoc = tz
+			}
+		}
+
+		// overridden instances, by UID and original start
+		overridden := make(map[string]map[int64]bool)
+		var events []*icalComponent
+		for _, ev := range cal.children("VEVENT") {
+			if rid := ev.get("RECURRENCE-ID"); rid != nil {
+				t, _, err := parseICalTime(rid, calLoc)
+				if err != nil {
+					skipped++
+					continue
+				}
+				uid := ev.text("UID")
+				if overridden[uid] == nil {
+					overridden[uid] = make(map[int64]bool)
+				}
+				overridden[uid][t.UTC().Unix()] = true
+			}
+			events = append(events, ev)
+		}
+
+		for _, ev := range events {
+			if strings.EqualFold(ev.text("TRANSP"), "TRANSPARENT") || strings.EqualFold(ev.text("STATUS"), "CANCELLED") {
+				continue
+			}
+			var skip map[int64]bool
+			if ev.get("RECURRENCE-ID") == nil {
+				skip = overridden[ev.text("UID")]
+			}
+			spans, err := icalOccurrences(ev, calLoc, from, to, skip)
+			if err != nil {
+				skipped++
+				continue
+			}
+			for _, s := range spans {
+				if s.End.After(s.Start) {
+					busy = append(busy, s)
+				}
+			}
+		}
+	}
+	return mergeIntervals(busy), skipped, nil
+}
+
+// CreateICSFeed stores a new feed for userID together with its first expansion.
+func (a *App) CreateICSFeed(ctx context.Context, feed *ICSFeed, busy []interval) error {
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return err
+	}
+	sealed, err := tc.seal(feed.url, connectionAAD(feed.UserID, icsFeedProvider))
+	if err != nil {
+		return err
+	}
+
+	tx, err := a.db(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	q := `INSERT INTO ics_feeds
+          (id, user_id, name, url, url_host, etag, last_modified, last_fetched_at, last_success_at, created_at, updated_at)
+          VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $7, $7, $7)
+          RETURNING id`
+	if err := tx.QueryRow(ctx, q, feed.UserID, nullIfEmpty(feed.Name), sealed, feed.Host,
+		nullIfEmpty(feed.etag), nullIfEmpty(feed.lastModified), now).Scan(&feed.ID); err != nil {
+		return err
+	}
+	if err := replaceICSFeedBusy(ctx, tx, feed.ID, feed.UserID, busy); err != nil {
+		return err
+	}
+	feed.LastFetchedAt, feed.LastSuccessAt, feed.CreatedAt = &now, &now, now
+	return tx.Commit(ctx)
+}
+
+// replaceICSFeedBusy swaps a feed's busy intervals for busy.
+func replaceICSFeedBusy(ctx context.Context, tx pgx.Tx, feedID, userID string, busy []interval) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM ics_feed_busy WHERE feed_id=$1`, feedID); err != nil {
+		return err
+	}
+	if len(busy) == 0 {
+		return nil
+	}
+	starts := make([]time.Time, len(busy))
+	ends := make([]time.Time, len(busy))
+	for i, b := range busy {
+		starts[i], ends[i] = b.Start, b.End
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO ics_feed_busy (feed_id, user_id, start_at_utc, end_at_utc)
+	                        SELECT $1, $2, s, e FROM unnest($3::timestamptz[], $4::timestamptz[]) AS t(s, e)`,
+		feedID, userID, starts, ends)
+	return err
+}
+
+const icsFeedColumns = `id, user_id, COALESCE(name,''), url_host, last_fetched_at, last_success_at,
+                        COALESCE(last_error,''), created_at, url, COALESCE(etag,''), COALESCE(last_modified,'')`
+
+// listICSFeeds returns feeds matching where, decrypting their URLs.
+func (a *App) listICSFeeds(ctx context.Context, where string, args ...any) ([]ICSFeed, error) {
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := a.db(ctx).Query(ctx, `SELECT `+icsFeedColumns+` FROM ics_feeds WHERE `+where+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ICSFeed
+	for rows.Next() {
+		var f ICSFeed
+		var sealed []byte
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Name, &f.Host, &f.LastFetchedAt, &f.LastSuccessAt,
+			&f.LastError, &f.CreatedAt, &sealed, &f.etag, &f.lastModified); err != nil {
+			return nil, err
+		}
+		if f.url, err = tc.open(sealed, connectionAAD(f.UserID, icsFeedProvider)); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// ListICSFeeds returns userID's feeds.
+func (a *App) ListICSFeeds(ctx context.Context, userID string) ([]ICSFeed, error) {
+	return a.listICSFeeds(ctx, "user_id=$1", userID)
+}
+
+// DeleteICSFeed removes a feed and its busy intervals, returning errICSFeedNotFound
+// when userID has no such feed.
+func (a *App) DeleteICSFeed(ctx context.Context, userID, feedID string) error {
+	tag, err := a.db(ctx).Exec(ctx, `DELETE FROM ics_feeds WHERE id=$1 AND user_id=$2`, feedID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errICSFeedNotFound
+	}
+	return nil
+}
+
+// icsBusy returns userID's cached ICS busy intervals overlapping [from, to).
+func (a *App) icsBusy(ctx context.Context, userID string, from, to time.Time) ([]interval, error) {
+	rows, err := a.db(ctx).Query(ctx, `SELECT start_at_utc, end_at_utc FROM ics_feed_busy
+	                              WHERE user_id=$1 AND start_at_utc < $3 AND end_at_utc > $2`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []interval
+	for rows.Next() {
+		var iv interval
+		if err := rows.Scan(&iv.Start, &iv.End); err != nil {
+			return nil, err
+		}
+		out = append(out, iv)
+	}
+	return out, rows.Err()
+}
+
+// userLocation returns userID's settings timezone, the default for floating feed times.
+func (a *App) userLocation(ctx context.Context, userID string) (*time.Location, error) {
+	settings, err := a.GetUserSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadTimezone(settings.Timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// refreshICSFeed polls one feed. A 304 only records the fetch; new content
+// replaces the feed's busy intervals. On failure the previous intervals stay
+// in place and the error is recorded on the feed.
+func (a *App) refreshICSFeed(ctx context.Context, feed ICSFeed) error {
+	etag, lastModified := feed.etag, feed.lastModified
+	if feed.LastSuccessAt == nil || time.Since(*feed.LastSuccessAt) > icsReexpandAfter {
+		etag, lastModified = "", ""
+	}
+
+	err := a.pullICSFeed(ctx, feed, etag, lastModified)
+	if err != nil {
+		_, dbErr := a.db(ctx).Exec(ctx, `UPDATE ics_feeds SET last_fetched_at=now(), last_error=$2, updated_at=now()
+		                            WHERE id=$1`, feed.ID, err.Error())
+		if dbErr != nil {
+			return dbErr
+		}
+	}
+	return err
+}
+
+func (a *App) pullICSFeed(ctx context.Context, feed ICSFeed, etag, lastModified string) error {
+	res, err := fetchICSFeed(ctx, feed.url, etag, lastModified)
+	if err != nil {
+		return err
+	}
+	if res.notModified {
+		_, err := a.db(ctx).Exec(ctx, `UPDATE ics_feeds SET last_fetched_at=now(), last_error=NULL, updated_at=now()
+		                          WHERE id=$1`, feed.ID)
+		return err
+	}
+
+	loc, err := a.userLocation(ctx, feed.UserID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	busy, skipped, err := icsBusyIntervals(res.body, loc, now.Add(-icsLookback), now.Add(icsHorizon))
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		log.Printf("ics feeds: skipped %d unparseable events in feed %s", skipped, feed.ID)
+	}
+
+	tx, err := a.db(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceICSFeedBusy(ctx, tx, feed.ID, feed.UserID, busy); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE ics_feeds SET etag=$2, last_modified=$3, last_fetched_at=$4, last_success_at=$4,
+	                       last_error=NULL, updated_at=$4 WHERE id=$1`,
+		feed.ID, nullIfEmpty(res.etag), nullIfEmpty(res.lastModified), now)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RunICSFeedRefresh polls feeds that are due every icsRefreshInterval,
+// checking every `every`, until ctx is cancelled.
+func (a *App) RunICSFeedRefresh(ctx context.Context, every time.Duration) {
+	ctx = asSystem(ctx)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		a.refreshDueICSFeeds(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *App) refreshDueICSFeeds(ctx context.Context) {
+	if _, err := tokenCipherFromEnv(); err != nil {
+		return
+	}
+	feeds, err := a.listICSFeeds(ctx, "last_fetched_at IS NULL OR last_fetched_at < $1",
+		time.Now().Add(-icsRefreshInterval))
+	if err != nil {
+		log.Printf("ics feeds: list due feeds: %v", err)
+		return
+	}
+	for _, feed := range feeds {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := a.refreshICSFeed(ctx, feed); err != nil {
+			log.Printf("ics feeds: refresh feed %s for user %s: %v", feed.ID, feed.UserID, err)
+		}
+	}
+}
+
+// POST /users/:id/ics-feeds
+func (a *App) CreateICSFeedHandler(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req struct {
+		URL  string `json:"url"`
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	u, err := parseICSFeedURL(req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	loc, err := a.userLocation(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// fetch once up front so a bad URL is rejected rather than stored
+	res, err := fetchICSFeed(ctx, u.String(), "", "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to fetch feed: " + err.Error()})
+		return
+	}
+	now := time.Now().UTC()
+	busy, _, err := icsBusyIntervals(res.body, loc, now.Add(-icsLookback), now.Add(icsHorizon))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid feed: " + err.Error()})
+		return
+	}
+
+	feed := &ICSFeed{
+		UserID:       userID,
+		Name:         strings.TrimSpace(req.Name),
+		Host:         u.Hostname(),
+		url:          u.String(),
+		etag:         res.etag,
+		lastModified: res.lastModified,
+	}
+	if err := a.CreateICSFeed(ctx, feed, busy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, feed)
+}
+
+// GET /users/:id/ics-feeds
+func (a *App) ListICSFeedsHandler(c *gin.Context) {
+	feeds, err := a.ListICSFeeds(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, feeds)
+}
+
+// DELETE /users/:id/ics-feeds/:feed_id
+func (a *App) DeleteICSFeedHandler(c *gin.Context) {
+	err := a.DeleteICSFeed(c.Request.Context(), c.Param("id"), c.Param("feed_id"))
+	if errors.Is(err, errICSFeedNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
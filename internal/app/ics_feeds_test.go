@@ -0,0 +1,293 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// vevent wraps content lines in a VEVENT.
+func vevent(lines ...string) string {
+	return "BEGIN:VEVENT\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VEVENT\r\n"
+}
+
+// vcalendar wraps calendar properties and events in a VCALENDAR.
+func vcalendar(header []string, events ...string) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n")
+	for _, h := range header {
+		b.WriteString(h + "\r\n")
+	}
+	for _, ev := range events {
+		b.WriteString(ev)
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
+
+func TestICSBusyIntervals(t *testing.T) {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) // a Monday
+	to := from.AddDate(0, 0, 14)
+
+	tests := []struct {
+		name        string
+		zone        string // the host's zone for floating times; UTC when empty
+		header      []string
+		events      []string
+		want        []string // "start/end" in RFC 3339, UTC
+		wantSkipped int
+	}{
+		{
+			name:   "single event",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000Z", "DTEND:20260302T100000Z")},
+			want:   []string{"2026-03-02T09:00:00Z/2026-03-02T10:00:00Z"},
+		},
+		{
+			name:   "daily count",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000Z", "DURATION:PT30M", "RRULE:FREQ=DAILY;COUNT=3")},
+			want: []string{
+				"2026-03-02T09:00:00Z/2026-03-02T09:30:00Z",
+				"2026-03-03T09:00:00Z/2026-03-03T09:30:00Z",
+				"2026-03-04T09:00:00Z/2026-03-04T09:30:00Z",
+			},
+		},
+		{
+			name:   "daily interval",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000Z", "DURATION:PT30M", "RRULE:FREQ=DAILY;INTERVAL=2;COUNT=3")},
+			want: []string{
+				"2026-03-02T09:00:00Z/2026-03-02T09:30:00Z",
+				"2026-03-04T09:00:00Z/2026-03-04T09:30:00Z",
+				"2026-03-06T09:00:00Z/2026-03-06T09:30:00Z",
+			},
+		},
+		{
+			name:   "until is inclusive",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000Z", "DURATION:PT30M", "RRULE:FREQ=DAILY;UNTIL=20260303T090000Z")},
+			want: []string{
+				"2026-03-02T09:00:00Z/2026-03-02T09:30:00Z",
+				"2026-03-03T09:00:00Z/2026-03-03T09:30:00Z",
+			},
+		},
+		{
+			name:   "weekly by day",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000Z", "DURATION:PT1H", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4")},
+			want: []string{
+				"2026-03-02T09:00:00Z/2026-03-02T10:00:00Z",
+				"2026-03-04T09:00:00Z/2026-03-04T10:00:00Z",
+				"2026-03-09T09:00:00Z/2026-03-09T10:00:00Z",
+				"2026-03-11T09:00:00Z/2026-03-11T10:00:00Z",
+			},
+		},
+		{
+			name:   "series starting before the window",
+			events: []string{vevent("UID:a", "DTSTART:20260223T090000Z", "DURATION:PT1H", "RRULE:FREQ=WEEKLY;INTERVAL=2")},
+			want:   []string{"2026-03-09T09:00:00Z/2026-03-09T10:00:00Z"},
+		},
+		{
+			name: "exdate",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000Z", "DURATION:PT30M", "RRULE:FREQ=DAILY;COUNT=3",
+				"EXDATE:20260303T090000Z")},
+			want: []string{
+				"2026-03-02T09:00:00Z/2026-03-02T09:30:00Z",
+				"2026-03-04T09:00:00Z/2026-03-04T09:30:00Z",
+			},
+		},
+		{
+			name:   "all-day event in the host's zone",
+			zone:   "America/New_York",
+			events: []string{vevent("UID:a", "DTSTART;VALUE=DATE:20260303", "DTEND;VALUE=DATE:20260304")},
+			want:   []string{"2026-03-03T05:00:00Z/2026-03-04T05:00:00Z"},
+		},
+		{
+			name:   "recurring all-day event",
+			events: []string{vevent("UID:a", "DTSTART;VALUE=DATE:20260303", "RRULE:FREQ=WEEKLY;COUNT=2")},
+			want: []string{
+				"2026-03-03T00:00:00Z/2026-03-04T00:00:00Z",
+				"2026-03-10T00:00:00Z/2026-03-11T00:00:00Z",
+			},
+		},
+		{
+			name:   "tzid",
+			events: []string{vevent("UID:a", "DTSTART;TZID=Europe/Berlin:20260302T090000", "DTEND;TZID=Europe/Berlin:20260302T100000")},
+			want:   []string{"2026-03-02T08:00:00Z/2026-03-02T09:00:00Z"},
+		},
+		{
+			name: "tzid keeps wall-clock time across DST",
+			events: []string{vevent("UID:a", "DTSTART;TZID=America/New_York:20260302T090000",
+				"DTEND;TZID=America/New_York:20260302T100000", "RRULE:FREQ=WEEKLY;COUNT=2")},
+			want: []string{
+				"2026-03-02T14:00:00Z/2026-03-02T15:00:00Z",
+				"2026-03-09T13:00:00Z/2026-03-09T14:00:00Z",
+			},
+		},
+		{
+			name:   "floating time in the host's zone",
+			zone:   "America/New_York",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000", "DTEND:20260302T100000")},
+			want:   []string{"2026-03-02T14:00:00Z/2026-03-02T15:00:00Z"},
+		},
+		{
+			name:   "floating time in the calendar's zone",
+			zone:   "America/New_York",
+			header: []string{"X-WR-TIMEZONE:Asia/Tokyo"},
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000", "DTEND:20260302T100000")},
+			want:   []string{"2026-03-02T00:00:00Z/2026-03-02T01:00:00Z"},
+		},
+		{
+			name: "free and cancelled events",
+			events: []string{
+				vevent("UID:a", "DTSTART:20260302T090000Z", "DTEND:20260302T100000Z", "TRANSP:TRANSPARENT"),
+				vevent("UID:b", "DTSTART:20260302T110000Z", "DTEND:20260302T120000Z", "STATUS:CANCELLED"),
+			},
+		},
+		{
+			name: "recurrence-id moves one instance",
+			events: []string{
+				vevent("UID:a", "DTSTART:20260302T090000Z", "DURATION:PT30M", "RRULE:FREQ=DAILY;COUNT=3"),
+				vevent("UID:a", "RECURRENCE-ID:20260303T090000Z", "DTSTART:20260303T150000Z", "DTEND:20260303T153000Z"),
+			},
+			want: []string{
+				"2026-03-02T09:00:00Z/2026-03-02T09:30:00Z",
+				"2026-03-03T15:00:00Z/2026-03-03T15:30:00Z",
+				"2026-03-04T09:00:00Z/2026-03-04T09:30:00Z",
+			},
+		},
+		{
+			name: "unparsable events are skipped",
+			events: []string{
+				vevent("UID:a", "DTSTART:not-a-time", "DTEND:20260302T100000Z"),
+				vevent("UID:b", "DTSTART:20260302T110000Z", "DTEND:20260302T120000Z"),
+			},
+			want:        []string{"2026-03-02T11:00:00Z/2026-03-02T12:00:00Z"},
+			wantSkipped: 1,
+		},
+		{
+			name:   "unbounded series stops at the window",
+			events: []string{vevent("UID:a", "DTSTART:20000103T090000Z", "DURATION:PT1H", "RRULE:FREQ=WEEKLY")},
+			want: []string{
+				"2026-03-02T09:00:00Z/2026-03-02T10:00:00Z",
+				"2026-03-09T09:00:00Z/2026-03-09T10:00:00Z",
+			},
+		},
+		{
+			// February never has a 30th, so only the cap ends the expansion
+			name:   "rule that never matches is capped",
+			events: []string{vevent("UID:a", "DTSTART:20260302T090000Z", "DURATION:PT1H", "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30")},
+			want:   []string{"2026-03-02T09:00:00Z/2026-03-02T10:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := time.UTC
+			if tt.zone != "" {
+				loc = mustLoad(t, tt.zone)
+			}
+			busy, skipped, err := icsBusyIntervals(vcalendar(tt.header, tt.events...), loc, from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, iv := range busy {
+				got = append(got, iv.Start.UTC().Format(time.RFC3339)+"/"+iv.End.UTC().Format(time.RFC3339))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("busy = %v, want %v", got, tt.want)
+			}
+			if skipped != tt.wantSkipped {
+				t.Errorf("skipped = %d, want %d", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestICSBusyIntervalsNeedsCalendar(t *testing.T) {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	if _, _, err := icsBusyIntervals("<html></html>", time.UTC, from, from.AddDate(0, 0, 1)); err == nil {
+		t.Error("non-calendar document parsed without error")
+	}
+}
+
+func TestFetchICSFeedConditionalGet(t *testing.T) {
+	t.Setenv("ICS_ALLOW_PRIVATE_HOSTS", "true")
+	const (
+		etag         = `"v1"`
+		lastModified = "Mon, 02 Mar 2026 09:00:00 GMT"
+	)
+	body := vcalendar(nil, vevent("UID:a", "DTSTART:20260302T090000Z", "DTEND:20260302T100000Z"))
+	var full atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	first, err := fetchICSFeed(ctx, srv.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.notModified || first.body != body || first.etag != etag || first.lastModified != lastModified {
+		t.Fatalf("first fetch = %+v", first)
+	}
+
+	for _, v := range []struct{ name, etag, lastModified string }{
+		{"etag", first.etag, ""},
+		{"last-modified", "", first.lastModified},
+		{"both", first.etag, first.lastModified},
+	} {
+		res, err := fetchICSFeed(ctx, srv.URL, v.etag, v.lastModified)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if !res.notModified || res.body != "" || res.etag != v.etag || res.lastModified != v.lastModified {
+			t.Errorf("%s: refetch = %+v, want not modified with the same validators", v.name, res)
+		}
+	}
+	if n := full.Load(); n != 1 {
+		t.Errorf("feed downloaded %d times, want once", n)
+	}
+
+	// a stale validator gets the full feed again
+	res, err := fetchICSFeed(ctx, srv.URL, `"v0"`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.notModified || res.etag != etag {
+		t.Errorf("stale etag refetch = %+v", res)
+	}
+}
+
+func TestFetchICSFeedRefusesPrivateHosts(t *testing.T) {
+	t.Setenv("ICS_ALLOW_PRIVATE_HOSTS", "false")
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	for _, feedURL := range []string{
+		srv.URL,                          // loopback
+		"http://10.0.0.1/cal.ics",        // private
+		"http://169.254.169.254/cal.ics", // link-local, e.g. cloud metadata
+		"http://[::1]:1/cal.ics",         // IPv6 loopback
+	} {
+		_, err := fetchICSFeed(context.Background(), feedURL, "", "")
+		if err == nil || !strings.Contains(err.Error(), "refusing to connect") {
+			t.Errorf("fetch %s: err = %v, want a refusal", feedURL, err)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("private server reached %d times", n)
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"sort"
+	"time"
+)
+
+// interval is a half-open [Start, End) span of time.
+type interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (i interval) overlaps(o interval) bool {
+	return i.Start.Before(o.End) && o.Start.Before(i.End)
+}
+
+// mergeIntervals returns the union of in as sorted, non-overlapping intervals.
+// Touching intervals are joined.
+func mergeIntervals(in []interval) []interval {
+	if len(in) == 0 {
+		return nil
+	}
+	sorted := make([]interval, len(in))
+	copy(sorted, in)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	out := []interval{sorted[0]}
+	for _, iv := range sorted[1:] {
+		last := &out[len(out)-1]
+		if iv.Start.After(last.End) {
+			out = append(out, iv)
+			continue
+		}
+		if iv.End.After(last.End) {
+			last.End = iv.End
+		}
+	}
+	return out
+}
+
+// subtractIntervals removes every interval in cut from base.
+// Both inputs may overlap; the result is sorted and non-overlapping.
+func subtractIntervals(base, cut []interval) []interval {
+	base = mergeIntervals(base)
+	cut = mergeIntervals(cut)
+
+	var out []interval
+	for _, b := range base {
+		cur := b
+		for _, c := range cut {
+			if !c.End.After(cur.Start) {
+				continue
+			}
+			if !c.Start.Before(cur.End) {
+				break
+			}
+			if c.Start.After(cur.Start) {
+				out = append(out, interval{Start: cur.Start, End: c.Start})
+			}
+			cur.Start = c.End
+			if !cur.Start.Before(cur.End) {
+				break
+			}
+		}
+		if cur.Start.Before(cur.End) {
+			out = append(out, cur)
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// clock returns the given HH:MM on Monday 2026-03-02 in UTC.
+func clock(t *testing.T, hhmm string) time.Time {
+	t.Helper()
+	ts, err := time.Parse("2006-01-02 15:04", "2026-03-02 "+hhmm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+// spans builds intervals from "HH:MM-HH:MM" strings on the day clock uses.
+func spans(t *testing.T, in ...string) []interval {
+	t.Helper()
+	var out []interval
+	for _, s := range in {
+		out = append(out, interval{Start: clock(t, s[:5]), End: clock(t, s[6:])})
+	}
+	return out
+}
+
+func sameIntervals(got, want []interval) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMergeIntervals(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"empty", nil, nil},
+		{"disjoint stay apart", []string{"09:00-10:00", "11:00-12:00"}, []string{"09:00-10:00", "11:00-12:00"}},
+		{"overlapping join", []string{"09:00-10:30", "10:00-11:00"}, []string{"09:00-11:00"}},
+		{"touching join", []string{"09:00-10:00", "10:00-11:00"}, []string{"09:00-11:00"}},
+		{"contained", []string{"09:00-12:00", "10:00-11:00"}, []string{"09:00-12:00"}},
+		{"unsorted", []string{"13:00-14:00", "09:00-10:00", "09:30-11:00"}, []string{"09:00-11:00", "13:00-14:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeIntervals(spans(t, tt.in...))
+			if want := spans(t, tt.want...); !sameIntervals(got, want) {
+				t.Errorf("mergeIntervals(%v) = %v, want %v", tt.in, got, want)
+			}
+		})
+	}
+}
+
+func TestSubtractIntervals(t *testing.T) {
+	tests := []struct {
+		name      string
+		base, cut []string
+		want      []string
+	}{
+		{"nothing to cut", []string{"09:00-12:00"}, nil, []string{"09:00-12:00"}},
+		{"middle splits", []string{"09:00-12:00"}, []string{"10:00-11:00"}, []string{"09:00-10:00", "11:00-12:00"}},
+		{"start trimmed", []string{"09:00-12:00"}, []string{"08:00-10:00"}, []string{"10:00-12:00"}},
+		{"end trimmed", []string{"09:00-12:00"}, []string{"11:00-13:00"}, []string{"09:00-11:00"}},
+		{"covered", []string{"09:00-12:00"}, []string{"08:00-13:00"}, nil},
+		{"touching cut keeps all", []string{"09:00-12:00"}, []string{"12:00-13:00"}, []string{"09:00-12:00"}},
+		{"overlapping cuts", []string{"09:00-12:00"}, []string{"09:30-10:30", "10:00-11:00"}, []string{"09:00-09:30", "11:00-12:00"}},
+		{"cut spans bases", []string{"09:00-10:00", "11:00-12:00"}, []string{"09:30-11:30"}, []string{"09:00-09:30", "11:30-12:00"}},
+		{"overlapping bases merge first", []string{"09:00-11:00", "10:00-12:00"}, []string{"10:30-11:00"}, []string{"09:00-10:30", "11:00-12:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subtractIntervals(spans(t, tt.base...), spans(t, tt.cut...))
+			if want := spans(t, tt.want...); !sameIntervals(got, want) {
+				t.Errorf("subtractIntervals(%v, %v) = %v, want %v", tt.base, tt.cut, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksMaxAge is how long a fetched key set is trusted before it is reloaded.
+	jwksMaxAge = time.Hour
+	// jwksMinRefresh limits reloads on unknown key IDs, so tokens with made-up
+	// kids can't turn into a flood of requests to the identity provider.
+	jwksMinRefresh = time.Minute
+	// jwksMaxBytes caps the size of a key set document.
+	jwksMaxBytes = 1 << 20
+)
+
+var errJWKSKeyNotFound = errors.New("jwks: no key for token")
+
+// jwk is one JSON Web Key (RFC 7517); only public RSA and EC signing keys are used.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwksKeySet caches the verification keys published at a JWKS URL or kept in
+// a local file, reloading them when they age out or a token names an unknown kid.
+// mu guards only the cached state; fetches run without it, so a slow identity
+// provider never blocks tokens whose keys are already known.
+type jwksKeySet struct {
+	url  string
+	file string
+	http *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	loading  *jwksLoad // the reload in flight, if any
+}
+
+// jwksLoad is one reload, shared by every caller that needs it.
+type jwksLoad struct {
+	done chan struct{}
+	err  error // set before done is closed
+}
+
+func newJWKSKeySet(url, file string) *jwksKeySet {
+	return &jwksKeySet{url: url, file: file, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// key returns the public key for kid. An empty kid matches the only key of a
+// single-key set.
+func (s *jwksKeySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if k, ok, fresh := s.lookup(kid); ok && fresh {
+		return k, nil
+	}
+	err := s.reload(ctx)
+	// stale keys stay usable while the source is unreachable
+	if k, ok, _ := s.lookup(kid); ok {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, errJWKSKeyNotFound
+}
+
+// lookup returns the cached key for kid and whether the cache is still within
+// jwksMaxAge.
+func (s *jwksKeySet) lookup(kid string) (crypto.PublicKey, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fresh := time.Since(s.loadedAt) <= jwksMaxAge
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true, fresh
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok, fresh
+}
+
+// reload refreshes the cached keys, joining the reload already in flight if
+// there is one. A new fetch starts at most once per jwksMinRefresh, and
+// loadedAt advances even on failure so a broken endpoint is not retried on
+// every request.
+func (s *jwksKeySet) reload(ctx context.Context) error {
+	s.mu.Lock()
+	l := s.loading
+	if l == nil {
+		if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < jwksMinRefresh {
+			s.mu.Unlock()
+			return nil
+		}
+		l = &jwksLoad{done: make(chan struct{})}
+		s.loading = l
+		s.loadedAt = time.Now()
+		go s.load(l)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// load fetches and parses the key set for l. It outlives any one request, so
+// it is bounded by the HTTP client's timeout rather than a caller's context.
+func (s *jwksKeySet) load(l *jwksLoad) {
+	data, err := s.fetch(context.Background())
+	var keys map[string]crypto.PublicKey
+	if err == nil {
+		keys, err = parseJWKS(data)
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+	}
+	s.loading = nil
+	s.mu.Unlock()
+
+	l.err = err
+	close(l.done)
+}
+
+func (s *jwksKeySet) fetch(ctx context.Context) ([]byte, error) {
+	if s.url == "" {
+		return os.ReadFile(s.file)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: %s returned %s", s.url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, jwksMaxBytes))
+}
+
+// parseJWKS reads the signing keys of a JWK set, skipping keys meant for
+// encryption and key types it does not support.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks: no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("jwks: invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwks: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, errors.New("jwks: EC point is not on the curve")
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("jwks: unsupported key type %q", k.Kty)
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("jwks: invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
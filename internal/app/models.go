@@ -9,6 +9,8 @@ type AvailabilityRule struct {
 	StartTime      string    `json:"start_time"`
 	EndTime        string    `json:"end_time"`
 	SlotLengthMins int       `json:"slot_length_minutes"`
+	SlotIncrement  int       `json:"slot_increment_minutes,omitempty"` // step between slot starts; defaults to slot length
+	Timezone       string    `json:"timezone"`                         // IANA name, e.g. "America/New_York"; start/end are local to it
 	Title          string    `json:"title,omitempty"`
 	Available      bool      `json:"available"`
 	CreatedAt      time.Time `json:"created_at,omitempty"`
@@ -16,15 +18,100 @@ type AvailabilityRule struct {
 }
 
 type Booking struct {
+	ID                 string         `json:"id"`
+	UserID             string         `json:"user_id"`
+	CandidateEmail     string         `json:"candidate_email"`
+	StartAtUTC         time.Time      `json:"start_at_utc"`
+	EndAtUTC           time.Time      `json:"end_at_utc"`
+	Status             string         `json:"status"`
+	Source             string         `json:"source,omitempty"`
+	Type               string         `json:"type,omitempty"`
+	EventTypeID        string         `json:"event_type_id,omitempty"`
+	Description        string         `json:"description,omitempty"`
+	Title              string         `json:"title,omitempty"`
+	BufferBefore       int            `json:"buffer_before_minutes,omitempty"`
+	BufferAfter        int            `json:"buffer_after_minutes,omitempty"`
+	ExternalProvider   string         `json:"external_provider,omitempty"`
+	ExternalCalendarID string         `json:"external_calendar_id,omitempty"`
+	ExternalEventID    string         `json:"external_event_id,omitempty"`
+	CreatedAt          time.Time      `json:"created_at,omitempty"`
+	UpdatedAt          time.Time      `json:"updated_at,omitempty"`
+	History            []BookingEvent `json:"history,omitempty"`
+}
+
+// Booking statuses
+const (
+	bookingStatusConfirmed = "confirmed"
+	bookingStatusCancelled = "cancelled"
+)
+
+// Booking lifecycle actions recorded in booking_events
+const (
+	bookingActionCreated     = "created"
+	bookingActionRescheduled = "rescheduled"
+	bookingActionCancelled   = "cancelled"
+)
+
+// BookingEvent is one entry in a booking's history: a status transition or a move
+// to new times. Previous* are set when the times changed.
+type BookingEvent struct {
+	ID                 string     `json:"id"`
+	BookingID          string     `json:"booking_id"`
+	Action             string     `json:"action"`
+	FromStatus         string     `json:"from_status,omitempty"`
+	ToStatus           string     `json:"to_status"`
+	PreviousStartAtUTC *time.Time `json:"previous_start_at_utc,omitempty"`
+	PreviousEndAtUTC   *time.Time `json:"previous_end_at_utc,omitempty"`
+	StartAtUTC         time.Time  `json:"start_at_utc"`
+	EndAtUTC           time.Time  `json:"end_at_utc"`
+	Reason             string     `json:"reason,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// AvailabilityOverride replaces the weekly rules for a single calendar date,
+// or blocks the date entirely when Blocked is set.
+type AvailabilityOverride struct {
 	ID             string    `json:"id"`
 	UserID         string    `json:"user_id"`
-	CandidateEmail string    `json:"candidate_email"`
-	StartAtUTC     time.Time `json:"start_at_utc"`
-	EndAtUTC       time.Time `json:"end_at_utc"`
-	Status         string    `json:"status"`
-	Source         string    `json:"source,omitempty"`
-	Type           string    `json:"type,omitempty"`
-	Description    string    `json:"description,omitempty"`
+	Date           string    `json:"date"` // YYYY-MM-DD, local to Timezone
+	Blocked        bool      `json:"blocked"`
+	StartTime      string    `json:"start_time,omitempty"`
+	EndTime        string    `json:"end_time,omitempty"`
+	SlotLengthMins int       `json:"slot_length_minutes,omitempty"`
+	SlotIncrement  int       `json:"slot_increment_minutes,omitempty"`
+	Timezone       string    `json:"timezone"`
 	Title          string    `json:"title,omitempty"`
 	CreatedAt      time.Time `json:"created_at,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at,omitempty"`
+}
+
+// UserSettings holds per-user scheduling preferences.
+// A user without a stored row gets the zero value in their default timezone.
+type UserSettings struct {
+	UserID       string    `json:"user_id"`
+	BufferBefore int       `json:"buffer_before_minutes"` // free time required before each booking
+	BufferAfter  int       `json:"buffer_after_minutes"`  // free time required after each booking
+	MinNotice    int       `json:"min_notice_minutes"`    // earliest bookable start, relative to now; 0 = none
+	HorizonDays  int       `json:"booking_horizon_days"`  // latest bookable start, relative to now; 0 = unlimited
+	MaxPerDay    int       `json:"max_bookings_per_day"`  // 0 = no cap
+	MaxPerWeek   int       `json:"max_bookings_per_week"` // 0 = no cap
+	Timezone     string    `json:"timezone"`              // where days and weeks begin for the caps
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// EventType is a bookable kind of meeting offered by a host. Its duration replaces
+// the slot length of the availability rules it is booked against.
+type EventType struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	Name         string    `json:"name"`
+	Slug         string    `json:"slug"`
+	DurationMins int       `json:"duration_minutes"`
+	BufferBefore *int      `json:"buffer_before_minutes,omitempty"` // nil = use user settings
+	BufferAfter  *int      `json:"buffer_after_minutes,omitempty"`  // nil = use user settings
+	Location     string    `json:"location,omitempty"`
+	RuleIDs      []string  `json:"rule_ids,omitempty"` // empty = all of the host's rules
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
 }
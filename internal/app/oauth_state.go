@@ -0,0 +1,212 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/oauth2"
+)
+
+// oauthStateTTL is how long a user has to complete the provider's consent screen.
+const oauthStateTTL = 10 * time.Minute
+
+// oauthBrowserCookie holds a nonce binding a pending authorization to the
+// browser that started it, so a victim can't be made to complete someone
+// else's flow. Its path covers the start link and every provider's callback.
+// The start link is opened by top-level navigation, so the cookie is
+// first-party and doesn't depend on the API client's CORS or credentials mode.
+const (
+	oauthBrowserCookie     = "oauth_browser"
+	oauthBrowserCookiePath = "/oauth2callback"
+	oauthStartPath         = oauthBrowserCookiePath + "/start"
+)
+
+// errInvalidOAuthState is returned for unknown, reused, expired or mismatched state.
+var errInvalidOAuthState = errors.New("invalid or expired state")
+
+// oauthState is a pending authorization started by UserID.
+type oauthState struct {
+	UserID   string
+	Provider string
+	Verifier string // PKCE code verifier
+}
+
+func hashState(state string) []byte {
+	sum := sha256.Sum256([]byte(state))
+	return sum[:]
+}
+
+// createOAuthState stores a fresh random state and sealed PKCE verifier for
+// userID. Only a hash of the state is kept. The authorization has no browser
+// until its start link is opened.
+func (a *App) createOAuthState(ctx context.Context, userID, provider string) (string, error) {
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := tc.seal(oauth2.GenerateVerifier(), provider)
+	if err != nil {
+		return "", err
+	}
+
+	// opportunistically drop abandoned authorizations
+	if _, err := a.db(ctx).Exec(ctx, `DELETE FROM oauth_states WHERE expires_at < now()`); err != nil {
+		return "", err
+	}
+
+	q := `INSERT INTO oauth_states (state_hash, user_id, provider, code_verifier, expires_at, created_at)
+          VALUES ($1, $2, $3, $4, $5, now())`
+	if _, err := a.db(ctx).Exec(ctx, q, hashState(state), userID, provider, sealed,
+		time.Now().UTC().Add(oauthStateTTL)); err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// bindOAuthBrowser attaches a fresh browser nonce to the pending authorization
+// for state and returns it with the provider and PKCE verifier. Only the first
+// browser to open the start link gets one.
+func (a *App) bindOAuthBrowser(ctx context.Context, state string) (provider, verifier, browser string, err error) {
+	if state == "" {
+		return "", "", "", errInvalidOAuthState
+	}
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return "", "", "", err
+	}
+	if browser, err = randomToken(32); err != nil {
+		return "", "", "", err
+	}
+
+	q := `UPDATE oauth_states SET browser_hash=$2
+          WHERE state_hash=$1 AND browser_hash IS NULL AND expires_at > now()
+          RETURNING provider, code_verifier`
+	var sealed []byte
+	err = a.db(ctx).QueryRow(ctx, q, hashState(state), hashState(browser)).Scan(&provider, &sealed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", "", errInvalidOAuthState
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	if verifier, err = tc.open(sealed, provider); err != nil {
+		return "", "", "", errInvalidOAuthState
+	}
+	return provider, verifier, browser, nil
+}
+
+// oauthStartURL is the link a client opens, by top-level navigation, to begin
+// an authorization. It is served next to the provider's redirect URL.
+func oauthStartURL(redirectURL, state string) (string, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = oauthStartPath
+	u.RawQuery = url.Values{"state": {state}}.Encode()
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// OAuthStartHandler binds a pending authorization to the browser opening its
+// start link and redirects that browser to the provider's consent screen.
+// GET /oauth2callback/start?state=...
+func (a *App) OAuthStartHandler(c *gin.Context) {
+	state := c.Query("state")
+	provider, verifier, browser, err := a.bindOAuthBrowser(c.Request.Context(), state)
+	if errors.Is(err, errInvalidOAuthState) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start authorization"})
+		return
+	}
+
+	var authURL string
+	switch provider {
+	case providerGoogle:
+		if cfg := InitGoogleCalendarConfig(); cfg != nil {
+			authURL = cfg.Config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier))
+		}
+	case providerMicrosoft:
+		if cfg := InitMicrosoftCalendarConfig(); cfg != nil {
+			authURL = cfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+		}
+	}
+	if authURL == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": provider + " calendar not configured"})
+		return
+	}
+
+	setOAuthBrowserCookie(c, browser)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// setOAuthBrowserCookie hands the browser nonce of an authorization to the
+// browser starting it. The cookie is HttpOnly and SameSite=Lax, so it is sent
+// on the provider's top-level redirect back to the callback.
+func setOAuthBrowserCookie(c *gin.Context, browser string) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthBrowserCookie, browser, int(oauthStateTTL.Seconds()), oauthBrowserCookiePath, "", secure, true)
+}
+
+// takeOAuthBrowserCookie returns the browser nonce sent to a callback and clears the cookie.
+func takeOAuthBrowserCookie(c *gin.Context) string {
+	browser, _ := c.Cookie(oauthBrowserCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthBrowserCookie, "", -1, oauthBrowserCookiePath, "", false, true)
+	return browser
+}
+
+// consumeOAuthState deletes and returns the pending authorization for state.
+// A state can be used once, only with the provider it was created for, and
+// only from the browser holding its nonce.
+func (a *App) consumeOAuthState(ctx context.Context, state, browser, provider string) (*oauthState, error) {
+	if state == "" || browser == "" {
+		return nil, errInvalidOAuthState
+	}
+	tc, err := tokenCipherFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	q := `DELETE FROM oauth_states WHERE state_hash=$1
+          RETURNING browser_hash, user_id::text, provider, code_verifier, expires_at`
+	var (
+		st          oauthState
+		browserHash []byte
+		sealed      []byte
+		expiresAt   time.Time
+	)
+	err = a.db(ctx).QueryRow(ctx, q, hashState(state)).Scan(&browserHash, &st.UserID, &st.Provider, &sealed, &expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errInvalidOAuthState
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(browserHash, hashState(browser)) != 1 {
+		return nil, errInvalidOAuthState
+	}
+	if st.Provider != provider || time.Now().After(expiresAt) {
+		return nil, errInvalidOAuthState
+	}
+	if st.Verifier, err = tc.open(sealed, provider); err != nil {
+		return nil, errInvalidOAuthState
+	}
+	return &st, nil
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// redirectTransport sends every request to target, standing in for the
+// provider's token endpoint.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	req.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// oauthFlow drives the Google authorization endpoints the way a SPA and a
+// browser do: the SPA asks for auth_url with its bearer token, the browser
+// opens it and later follows the provider's redirect to the callback.
+type oauthFlow struct {
+	t      *testing.T
+	router *gin.Engine
+	userID string
+
+	mu        sync.Mutex
+	verifiers []string // code_verifier of each token exchange
+	tokenHTTP *http.Client
+}
+
+func newOAuthFlow(t *testing.T) *oauthFlow {
+	gin.SetMode(gin.TestMode)
+	a := testApp(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	t.Setenv("GOOGLE_CLIENT_ID", "client")
+	t.Setenv("GOOGLE_CLIENT_SECRET", "secret")
+	t.Setenv("GOOGLE_REDIRECT_URL", "https://api.example.com/oauth2callback")
+
+	f := &oauthFlow{t: t}
+	host := User{DisplayName: "Host", Timezone: "UTC"}
+	if err := a.CreateUser(context.Background(), &host, ""); err != nil {
+		t.Fatal(err)
+	}
+	f.userID = host.ID
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("token request: %v", err)
+		}
+		f.mu.Lock()
+		f.verifiers = append(f.verifiers, r.PostForm.Get("code_verifier"))
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access", "refresh_token": "refresh", "token_type": "Bearer", "expires_in": 3600,
+		})
+	}))
+	t.Cleanup(tokens.Close)
+	target, _ := url.Parse(tokens.URL)
+	f.tokenHTTP = &http.Client{Transport: redirectTransport{target: target}}
+
+	f.router = gin.New()
+	f.router.GET("/api/calendar/auth", a.GoogleAuthHandler)
+	f.router.GET("/oauth2callback/start", a.OAuthStartHandler)
+	f.router.GET("/oauth2callback", func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), oauth2.HTTPClient, f.tokenHTTP)
+		c.Request = c.Request.WithContext(ctx)
+	}, a.GoogleOAuth2CallbackHandler)
+	return f
+}
+
+func (f *oauthFlow) get(target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+// begin asks for an authorization and returns its start link.
+func (f *oauthFlow) begin() *url.URL {
+	w := f.get("/api/calendar/auth?user_id=" + f.userID)
+	if w.Code != http.StatusOK {
+		f.t.Fatalf("auth: %d %s", w.Code, w.Body)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		f.t.Errorf("auth sets cookies on the API response: %v", cookies)
+	}
+	var resp struct {
+		AuthURL string `json:"auth_url"`
+		State   string `json:"state"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		f.t.Fatal(err)
+	}
+	start, err := url.Parse(resp.AuthURL)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if start.Host != "api.example.com" || start.Path != oauthStartPath || start.Query().Get("state") != resp.State {
+		f.t.Fatalf("auth_url = %s, want the start link for state %s", resp.AuthURL, resp.State)
+	}
+	return start
+}
+
+// open follows a start link like a browser, returning the provider URL it
+// redirects to and the browser cookie it sets.
+func (f *oauthFlow) open(start *url.URL) (*url.URL, *http.Cookie) {
+	w := f.get(start.RequestURI())
+	if w.Code != http.StatusFound {
+		f.t.Fatalf("start: %d %s", w.Code, w.Body)
+	}
+	var browser *http.Cookie
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == oauthBrowserCookie {
+			browser = ck
+		}
+	}
+	if browser == nil || !browser.HttpOnly || browser.SameSite != http.SameSiteLaxMode || browser.Path != oauthBrowserCookiePath {
+		f.t.Fatalf("browser cookie = %+v", browser)
+	}
+	consent, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return consent, browser
+}
+
+func TestOAuthStartToCallback(t *testing.T) {
+	f := newOAuthFlow(t)
+	start := f.begin()
+	consent, browser := f.open(start)
+
+	state := start.Query().Get("state")
+	q := consent.Query()
+	if consent.Host != "accounts.google.com" || q.Get("state") != state ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("access_type") != "offline" {
+		t.Fatalf("consent URL = %s", consent)
+	}
+
+	// a second browser can't take over the link
+	if w := f.get(start.RequestURI()); w.Code != http.StatusBadRequest {
+		t.Errorf("reopened start link: %d %s", w.Code, w.Body)
+	}
+
+	w := f.get("/oauth2callback?"+url.Values{"state": {state}, "code": {"code"}}.Encode(), browser)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), f.userID) {
+		t.Fatalf("callback: %d %s", w.Code, w.Body)
+	}
+	if len(f.verifiers) != 1 || oauth2.S256ChallengeFromVerifier(f.verifiers[0]) != q.Get("code_challenge") {
+		t.Errorf("token exchange verifiers = %v, want the one behind code_challenge", f.verifiers)
+	}
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == oauthBrowserCookie && ck.MaxAge >= 0 {
+			t.Errorf("callback keeps the browser cookie: %+v", ck)
+		}
+	}
+}
+
+func TestOAuthCallbackRejectsOtherBrowser(t *testing.T) {
+	f := newOAuthFlow(t)
+	start := f.begin()
+	f.open(start)
+	state := start.Query().Get("state")
+	callback := "/oauth2callback?" + url.Values{"state": {state}, "code": {"code"}}.Encode()
+
+	other := &http.Cookie{Name: oauthBrowserCookie, Value: "someone-else"}
+	if w := f.get(callback, other); w.Code != http.StatusBadRequest {
+		t.Errorf("callback from another browser: %d %s", w.Code, w.Body)
+	}
+	if w := f.get(callback); w.Code != http.StatusBadRequest {
+		t.Errorf("callback without the cookie: %d %s", w.Code, w.Body)
+	}
+	if len(f.verifiers) != 0 {
+		t.Errorf("code exchanged for a rejected callback")
+	}
+
+	// an authorization nobody opened can't be completed either
+	unopened := f.begin().Query().Get("state")
+	if w := f.get("/oauth2callback?" + url.Values{"state": {unopened}, "code": {"code"}}.Encode()); w.Code != http.StatusBadRequest {
+		t.Errorf("callback for an unopened link: %d %s", w.Code, w.Body)
+	}
+}
@@ -2,8 +2,14 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 // Slot DTO
@@ -12,83 +18,512 @@ type Slot struct {
 	EndUTC   time.Time `json:"end_utc"`
 }
 
-// generateSlots expands availability rules into slots in UTC between from/to inclusive,
-// considering available=true rules and excluding available=false (unavailable) rules.
-// It relies only on availability_rules table and existing bookings.
-func (a *App) GenerateAvailableSlots(ctx context.Context, userID string, fromUTC, toUTC time.Time) ([]Slot, error) {
+// window is a bookable stretch of time on one local date, before it is chunked into slots.
+type window struct {
+	Start   time.Time
+	End     time.Time
+	SlotLen time.Duration
+	Step    time.Duration // distance between consecutive slot starts
+}
+
+// slotShape groups windows that chunk into slots the same way.
+type slotShape struct {
+	SlotLen time.Duration
+	Step    time.Duration
+}
+
+func newSlotShape(slotLenMins, incrementMins int) slotShape {
+	shape := slotShape{SlotLen: time.Duration(slotLenMins) * time.Minute}
+	shape.Step = shape.SlotLen
+	if incrementMins > 0 {
+		shape.Step = time.Duration(incrementMins) * time.Minute
+	}
+	return shape
+}
+
+// SlotOptions narrows slot generation to one kind of meeting.
+type SlotOptions struct {
+	// EventType, when set, supplies the slot duration and buffers and may
+	// restrict which availability rules are used.
+	EventType *EventType
+	// ExcludeBookingID ignores one booking when checking conflicts and caps,
+	// so a booking being rescheduled does not block its own new slot.
+	ExcludeBookingID string
+
+	// skipBusy leaves out the external calendar lookup when the caller has just done it.
+	skipBusy bool
+}
+
+// generateSlots expands availability rules into slots in UTC between from/to inclusive.
+// Overlapping available=true rules are merged, and available=false (unavailable) rules
+// subtract their time range from them before the result is chunked into slots.
+// Rule times are wall-clock times in the rule's timezone, so day_of_week is matched
+// against the local weekday and DST transitions are resolved per day.
+// Date overrides are applied first: a blocked date yields no windows, and any other
+// override replaces the weekly rules for its date.
+// When opts.EventType is set its duration replaces the rules' slot length.
+// It relies only on availability_rules, availability_overrides and existing bookings.
+func (a *App) GenerateAvailableSlots(ctx context.Context, userID string, fromUTC, toUTC time.Time, opts SlotOptions) ([]Slot, error) {
 	// fetch user's rules
 	rules, err := a.ListAvailabilityRules(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	if len(rules) == 0 {
+	rules = opts.EventType.restrictRules(rules)
+	// local dates never differ from UTC ones by more than a day
+	overrides, err := a.ListAvailabilityOverrides(ctx, userID, fromUTC.AddDate(0, 0, -1), toUTC.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 && len(overrides) == 0 {
 		return nil, nil
 	}
 
-	var candidateSlots []Slot
+	windows, err := buildWindows(rules, overrides, fromUTC, toUTC, opts.EventType.duration())
+	if err != nil {
+		return nil, err
+	}
 
-	// We'll iterate each date between fromUTC.Date and toUTC.Date in UTC
-	startDate := fromUTC.Truncate(24 * time.Hour)
-	endDate := toUTC.Truncate(24 * time.Hour)
+	candidateSlots := windowSlots(windows, fromUTC, toUTC)
 
-	for day := startDate; !day.After(endDate); day = day.Add(24 * time.Hour) {
-		for _, r := range rules {
-			// Check if this day matches the rule's day of week (in UTC)
-			if int(day.Weekday()) != r.DayOfWeek {
-				continue
-			}
-			// parse start and end time (HH:MM) - now in UTC
-			startTOD, err := parseHHMM(r.StartTime)
-			if err != nil {
-				return nil, err
-			}
-			endTOD, err := parseHHMM(r.EndTime)
-			if err != nil {
-				return nil, err
-			}
-			if !endTOD.After(startTOD) {
-				return nil, fmt.Errorf("end_time must be after start_time for rule %d", r.ID)
-			}
-			// build UTC datetime
-			year, month, dayNum := day.Date()
-			utcStart := time.Date(year, month, dayNum, startTOD.Hour(), startTOD.Minute(), 0, 0, time.UTC)
-			utcEnd := time.Date(year, month, dayNum, endTOD.Hour(), endTOD.Minute(), 0, 0, time.UTC)
-
-			// chunk into slots
-			slotLen := time.Duration(r.SlotLengthMins) * time.Minute
-			for s := utcStart; s.Add(slotLen).Equal(utcEnd) || s.Add(slotLen).Before(utcEnd); s = s.Add(slotLen) {
-				startUTC := s
-				endUTC := s.Add(slotLen)
-				if !endUTC.After(fromUTC) || !startUTC.Before(toUTC) {
-					continue
-				}
-				if !r.Available {
-					continue
-				}
-				candidateSlots = append(candidateSlots, Slot{StartUTC: startUTC, EndUTC: endUTC})
-			}
+	settings, err := a.GetUserSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	before, after := opts.EventType.buffers(settings)
+
+	// drop slots outside the minimum notice / booking horizon window
+	now := time.Now().UTC()
+	var bookable []Slot
+	for _, s := range candidateSlots {
+		if code, _ := settings.checkBookingWindow(now, s.StartUTC); code == "" {
+			bookable = append(bookable, s)
 		}
 	}
+	candidateSlots = bookable
 
-	// remove slots that have confirmed bookings
-	bookings, err := a.ListBookingsInRange(ctx, userID, fromUTC.Add(-1*time.Hour), toUTC.Add(1*time.Hour))
+	// remove slots that overlap confirmed bookings, including their buffers
+	bookings, err := a.ListBookingsInRange(ctx, userID, fromUTC.Add(-maxBuffer), toUTC.Add(maxBuffer))
 	if err != nil {
 		return nil, err
 	}
-	bookedMap := map[int64]struct{}{}
-	for _, b := range bookings {
-		bookedMap[b.StartAtUTC.Unix()] = struct{}{}
-	}
+	bookings = excludeBooking(bookings, opts.ExcludeBookingID)
 
 	var available []Slot
 	for _, s := range candidateSlots {
-		if _, ok := bookedMap[s.StartUTC.Unix()]; !ok {
+		if !conflictsWithBooking(interval{Start: s.StartUTC, End: s.EndUTC}, before, after, bookings) {
 			available = append(available, s)
 		}
 	}
+
+	// remove slots that collide with the host's external calendar
+	if !opts.skipBusy && len(available) > 0 {
+		busy, err := a.externalBusy(ctx, userID, fromUTC.Add(-before), toUTC.Add(after))
+		if err != nil {
+			return nil, err
+		}
+		var free []Slot
+		for _, s := range available {
+			if !conflictsWithBusy(interval{Start: s.StartUTC, End: s.EndUTC}, before, after, busy) {
+				free = append(free, s)
+			}
+		}
+		available = free
+	}
+
+	// hide everything on days and weeks that are already full
+	if settings.hasCaps() {
+		loc, err := loadTimezone(settings.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		rangeStart, _ := localWeekBounds(fromUTC, loc)
+		_, rangeEnd := localWeekBounds(toUTC, loc)
+		capped, err := a.ListBookingsInRange(ctx, userID, rangeStart, rangeEnd)
+		if err != nil {
+			return nil, err
+		}
+		counter := newCapCounter(settings, loc, excludeBooking(capped, opts.ExcludeBookingID))
+
+		var uncapped []Slot
+		for _, s := range available {
+			if code, _ := counter.check(s.StartUTC); code == "" {
+				uncapped = append(uncapped, s)
+			}
+		}
+		available = uncapped
+	}
 	return available, nil
 }
 
+// bookingError is a reason a slot cannot be booked, reported to the client as-is.
+type bookingError struct {
+	Status int
+	Code   string
+	Msg    string
+}
+
+func (e *bookingError) respond(c *gin.Context) {
+	body := gin.H{"error": e.Msg}
+	if e.Code != "" {
+		body["code"] = e.Code
+	}
+	c.JSON(e.Status, body)
+}
+
+// checkSlotBookable runs every check a new or moved booking must pass: no overlap,
+// buffers, daily/weekly caps, external calendar busy time and membership in the
+// generated slots. It must run
+// inside tx after lockUserBookings. excludeID names a booking to ignore, i.e. the
+// one being rescheduled. A nil *bookingError means the slot can be booked.
+func (a *App) checkSlotBookable(ctx context.Context, tx pgx.Tx, userID string, start, end time.Time,
+	settings UserSettings, eventType *EventType, excludeID string) (*bookingError, error) {
+	// check overlapping confirmed booking
+	checkQ := `SELECT id FROM bookings 
+			   WHERE user_id=$1 AND status='confirmed' 
+			   AND tstzrange(start_at_utc, end_at_utc) && tstzrange($2, $3)
+			   AND ($4 = '' OR id::text <> $4)
+			   AND tenant_id=$5
+			   LIMIT 1 FOR UPDATE`
+	var existingID string
+	err := tx.QueryRow(ctx, checkQ, userID, start, end, excludeID, tenantArg(ctx)).Scan(&existingID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if existingID != "" {
+		return &bookingError{Status: http.StatusConflict, Msg: "slot already booked"}, nil
+	}
+
+	// check buffers around neighbouring bookings
+	nearby, err := listConfirmedBookings(ctx, tx, userID, start.Add(-maxBuffer), end.Add(maxBuffer))
+	if err != nil {
+		return nil, err
+	}
+	before, after := eventType.buffers(settings)
+	if conflictsWithBooking(interval{Start: start, End: end}, before, after, excludeBooking(nearby, excludeID)) {
+		return &bookingError{Status: http.StatusConflict, Msg: "slot overlaps the buffer around an existing booking"}, nil
+	}
+
+	// check daily/weekly caps; the caller's lock keeps concurrent requests from overshooting
+	if settings.hasCaps() {
+		loc, err := loadTimezone(settings.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		weekStart, weekEnd := localWeekBounds(start, loc)
+		week, err := listConfirmedBookings(ctx, tx, userID, weekStart, weekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if code, msg := newCapCounter(settings, loc, excludeBooking(week, excludeID)).check(start); code != "" {
+			return &bookingError{Status: http.StatusConflict, Code: code, Msg: msg}, nil
+		}
+	}
+
+	// recheck the host's external calendar right before committing
+	busy, err := a.externalBusy(ctx, userID, start.Add(-before), end.Add(after))
+	if err != nil {
+		return nil, err
+	}
+	if conflictsWithBusy(interval{Start: start, End: end}, before, after, busy) {
+		return &bookingError{Status: http.StatusConflict, Msg: "slot conflicts with the host's calendar"}, nil
+	}
+
+	// verify slot belongs to user's availability
+	slots, err := a.GenerateAvailableSlots(ctx, userID, start.Add(-1*time.Second), end.Add(1*time.Second),
+		SlotOptions{EventType: eventType, ExcludeBookingID: excludeID, skipBusy: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range slots {
+		if s.StartUTC.Equal(start) && s.EndUTC.Equal(end) {
+			return nil, nil
+		}
+	}
+	return &bookingError{Status: http.StatusBadRequest, Msg: "slot not available"}, nil
+}
+
+// excludeBooking returns bookings without the one whose ID is id.
+func excludeBooking(bookings []Booking, id string) []Booking {
+	if id == "" {
+		return bookings
+	}
+	var out []Booking
+	for _, b := range bookings {
+		if b.ID != id {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// restrictRules keeps the available rules an event type is limited to.
+// Unavailable rules always apply, and a nil event type keeps every rule.
+func (et *EventType) restrictRules(rules []AvailabilityRule) []AvailabilityRule {
+	if et == nil || len(et.RuleIDs) == 0 {
+		return rules
+	}
+	allowed := map[string]bool{}
+	for _, id := range et.RuleIDs {
+		allowed[id] = true
+	}
+	var out []AvailabilityRule
+	for _, r := range rules {
+		if !r.Available || allowed[r.ID] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// duration returns the event type's length in minutes, or 0 for a nil event type.
+func (et *EventType) duration() int {
+	if et == nil {
+		return 0
+	}
+	return et.DurationMins
+}
+
+// buffers returns the padding around a booking, preferring the event type's
+// own buffers over the host's settings.
+func (et *EventType) buffers(settings UserSettings) (before, after time.Duration) {
+	beforeMins, afterMins := settings.BufferBefore, settings.BufferAfter
+	if et != nil && et.BufferBefore != nil {
+		beforeMins = *et.BufferBefore
+	}
+	if et != nil && et.BufferAfter != nil {
+		afterMins = *et.BufferAfter
+	}
+	return time.Duration(beforeMins) * time.Minute, time.Duration(afterMins) * time.Minute
+}
+
+// Error codes returned when a booking falls outside the bookable window.
+const (
+	codeStartInPast   = "start_in_past"
+	codeMinNotice     = "min_notice_violation"
+	codeBeyondHorizon = "beyond_booking_horizon"
+)
+
+// checkBookingWindow validates a slot start against the minimum notice and
+// booking horizon. It returns an empty code when the start is bookable.
+func (s UserSettings) checkBookingWindow(now, start time.Time) (code, msg string) {
+	if start.Before(now) {
+		return codeStartInPast, "start is in the past"
+	}
+	if s.MinNotice > 0 && start.Before(now.Add(time.Duration(s.MinNotice)*time.Minute)) {
+		return codeMinNotice, fmt.Sprintf("bookings require at least %d minutes notice", s.MinNotice)
+	}
+	if s.HorizonDays > 0 && start.After(now.AddDate(0, 0, s.HorizonDays)) {
+		return codeBeyondHorizon, fmt.Sprintf("bookings can be made at most %d days in advance", s.HorizonDays)
+	}
+	return "", ""
+}
+
+// Error codes returned when a host has reached a booking cap.
+const (
+	codeDailyCap  = "daily_cap_reached"
+	codeWeeklyCap = "weekly_cap_reached"
+)
+
+func (s UserSettings) hasCaps() bool {
+	return s.MaxPerDay > 0 || s.MaxPerWeek > 0
+}
+
+// capCounter counts confirmed bookings per local day and week of the host.
+type capCounter struct {
+	settings UserSettings
+	loc      *time.Location
+	perDay   map[time.Time]int
+	perWeek  map[time.Time]int
+}
+
+// newCapCounter buckets bookings by the local day and week in which they start.
+func newCapCounter(settings UserSettings, loc *time.Location, bookings []Booking) *capCounter {
+	c := &capCounter{
+		settings: settings,
+		loc:      loc,
+		perDay:   map[time.Time]int{},
+		perWeek:  map[time.Time]int{},
+	}
+	for _, b := range bookings {
+		day, _ := localDayBounds(b.StartAtUTC, loc)
+		week, _ := localWeekBounds(b.StartAtUTC, loc)
+		c.perDay[day]++
+		c.perWeek[week]++
+	}
+	return c
+}
+
+// check reports whether one more booking starting at start would exceed a cap.
+// It returns an empty code when the booking fits.
+func (c *capCounter) check(start time.Time) (code, msg string) {
+	day, _ := localDayBounds(start, c.loc)
+	week, _ := localWeekBounds(start, c.loc)
+	if c.settings.MaxPerDay > 0 && c.perDay[day] >= c.settings.MaxPerDay {
+		return codeDailyCap, fmt.Sprintf("host accepts at most %d bookings per day", c.settings.MaxPerDay)
+	}
+	if c.settings.MaxPerWeek > 0 && c.perWeek[week] >= c.settings.MaxPerWeek {
+		return codeWeeklyCap, fmt.Sprintf("host accepts at most %d bookings per week", c.settings.MaxPerWeek)
+	}
+	return "", ""
+}
+
+// maxBuffer bounds buffer_before_minutes/buffer_after_minutes, and so how far
+// outside a range a booking can still affect it.
+const maxBuffer = 24 * time.Hour
+
+// conflictsWithBooking reports whether a slot, padded by before/after, collides
+// with a booking, or falls inside the buffers recorded on that booking.
+func conflictsWithBooking(slot interval, before, after time.Duration, bookings []Booking) bool {
+	padded := interval{Start: slot.Start.Add(-before), End: slot.End.Add(after)}
+	for _, b := range bookings {
+		booked := interval{Start: b.StartAtUTC, End: b.EndAtUTC}
+		bookedPadded := interval{
+			Start: b.StartAtUTC.Add(-time.Duration(b.BufferBefore) * time.Minute),
+			End:   b.EndAtUTC.Add(time.Duration(b.BufferAfter) * time.Minute),
+		}
+		if padded.overlaps(booked) || slot.overlaps(bookedPadded) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildWindows resolves weekly rules and date overrides into concrete windows
+// for every local date touched by [fromUTC, toUTC]. Available windows with the
+// same slot length and increment are merged and unavailable rules are subtracted
+// from all of them. A positive durationMins replaces every slot length.
+func buildWindows(rules []AvailabilityRule, overrides []AvailabilityOverride, fromUTC, toUTC time.Time, durationMins int) ([]window, error) {
+	// dates that have any override no longer follow the weekly rules
+	overridden := map[string]bool{}
+	blocked := map[string]bool{}
+	for _, o := range overrides {
+		overridden[o.Date] = true
+		if o.Blocked {
+			blocked[o.Date] = true
+		}
+	}
+
+	// available time grouped by slot shape, and time carved out by unavailable rules
+	availableByShape := map[slotShape][]interval{}
+	var unavailable []interval
+
+	for _, r := range rules {
+		loc, err := loadTimezone(r.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+		// parse start and end time (HH:MM) - local wall-clock time
+		startTOD, endTOD, err := parseWindow(r.StartTime, r.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+
+		// iterate each local date between fromUTC and toUTC in the rule's timezone
+		for _, day := range localDates(fromUTC, toUTC, loc) {
+			if int(day.Weekday()) != r.DayOfWeek || overridden[day.Format(dateLayout)] {
+				continue
+			}
+			iv := interval{Start: wallClock(day, startTOD, loc), End: wallClock(day, endTOD, loc)}
+			if !r.Available {
+				unavailable = append(unavailable, iv)
+				continue
+			}
+			shape := newSlotShape(pickDuration(durationMins, r.SlotLengthMins), r.SlotIncrement)
+			availableByShape[shape] = append(availableByShape[shape], iv)
+		}
+	}
+
+	for _, o := range overrides {
+		if o.Blocked || blocked[o.Date] {
+			continue
+		}
+		loc, err := loadTimezone(o.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("override %s: %w", o.ID, err)
+		}
+		startTOD, endTOD, err := parseWindow(o.StartTime, o.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("override %s: %w", o.ID, err)
+		}
+		day, err := time.Parse(dateLayout, o.Date)
+		if err != nil {
+			return nil, fmt.Errorf("override %s: %w", o.ID, err)
+		}
+		shape := newSlotShape(pickDuration(durationMins, o.SlotLengthMins), o.SlotIncrement)
+		availableByShape[shape] = append(availableByShape[shape],
+			interval{Start: wallClock(day, startTOD, loc), End: wallClock(day, endTOD, loc)})
+	}
+
+	var out []window
+	for shape, ivs := range availableByShape {
+		for _, iv := range subtractIntervals(ivs, unavailable) {
+			out = append(out, window{Start: iv.Start, End: iv.End, SlotLen: shape.SlotLen, Step: shape.Step})
+		}
+	}
+	return out, nil
+}
+
+func pickDuration(override, fallback int) int {
+	if override > 0 {
+		return override
+	}
+	return fallback
+}
+
+// windowSlots chunks windows into slots a step apart, keeping those that
+// overlap [fromUTC, toUTC], sorted and without repeats.
+func windowSlots(windows []window, fromUTC, toUTC time.Time) []Slot {
+	var out []Slot
+	for _, w := range windows {
+		for s := w.Start; !s.Add(w.SlotLen).After(w.End); s = s.Add(w.Step) {
+			startUTC := s.UTC()
+			endUTC := s.Add(w.SlotLen).UTC()
+			if !endUTC.After(fromUTC) || !startUTC.Before(toUTC) {
+				continue
+			}
+			out = append(out, Slot{StartUTC: startUTC, EndUTC: endUTC})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].StartUTC.Equal(out[j].StartUTC) {
+			return out[i].StartUTC.Before(out[j].StartUTC)
+		}
+		return out[i].EndUTC.Before(out[j].EndUTC)
+	})
+	return dedupeSlots(out)
+}
+
+// dedupeSlots drops repeated slots from a sorted list; windows of different
+// shapes can still produce the same start/end pair.
+func dedupeSlots(slots []Slot) []Slot {
+	var out []Slot
+	for i, s := range slots {
+		if i > 0 && s.StartUTC.Equal(slots[i-1].StartUTC) && s.EndUTC.Equal(slots[i-1].EndUTC) {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// parseWindow parses a start/end time-of-day pair and checks their order.
+func parseWindow(start, end string) (time.Time, time.Time, error) {
+	startTOD, err := parseHHMM(start)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	endTOD, err := parseHHMM(end)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !endTOD.After(startTOD) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_time must be after start_time")
+	}
+	return startTOD, endTOD, nil
+}
+
 func parseHHMM(s string) (time.Time, error) {
 	// Handle different time formats from database
 	// "07:00:00.000000" -> "07:00"
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckBookingWindow(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		settings UserSettings
+		start    time.Time
+		want     string
+	}{
+		{"past", UserSettings{}, now.Add(-time.Minute), codeStartInPast},
+		{"now", UserSettings{}, now, ""},
+		{"no limits", UserSettings{}, now.AddDate(1, 0, 0), ""},
+		{"inside notice", UserSettings{MinNotice: 60}, now.Add(59 * time.Minute), codeMinNotice},
+		{"at notice", UserSettings{MinNotice: 60}, now.Add(60 * time.Minute), ""},
+		{"at horizon", UserSettings{HorizonDays: 7}, now.AddDate(0, 0, 7), ""},
+		{"beyond horizon", UserSettings{HorizonDays: 7}, now.AddDate(0, 0, 7).Add(time.Minute), codeBeyondHorizon},
+		{"notice checked before horizon", UserSettings{MinNotice: 60, HorizonDays: 1}, now.Add(30 * time.Minute), codeMinNotice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, msg := tt.settings.checkBookingWindow(now, tt.start); code != tt.want {
+				t.Errorf("checkBookingWindow(%s) = %q (%s), want %q", tt.start, code, msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapCounter(t *testing.T) {
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	booked := func(starts ...string) []Booking {
+		var out []Booking
+		for _, s := range starts {
+			out = append(out, Booking{StartAtUTC: at(s), EndAtUTC: at(s).Add(time.Hour)})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		settings UserSettings
+		zone     string
+		bookings []Booking
+		start    string
+		want     string
+	}{
+		{"no caps", UserSettings{}, "UTC", booked("2026-03-02T10:00:00Z"), "2026-03-02T11:00:00Z", ""},
+		{"day full", UserSettings{MaxPerDay: 1}, "UTC", booked("2026-03-02T10:00:00Z"), "2026-03-02T15:00:00Z", codeDailyCap},
+		{"next day free", UserSettings{MaxPerDay: 1}, "UTC", booked("2026-03-02T10:00:00Z"), "2026-03-03T10:00:00Z", ""},
+		{"week full from monday to sunday", UserSettings{MaxPerWeek: 2}, "UTC",
+			booked("2026-03-02T09:00:00Z", "2026-03-08T20:00:00Z"), "2026-03-04T10:00:00Z", codeWeeklyCap},
+		{"next monday starts a new week", UserSettings{MaxPerWeek: 2}, "UTC",
+			booked("2026-03-02T09:00:00Z", "2026-03-08T20:00:00Z"), "2026-03-09T00:00:00Z", ""},
+		{"previous sunday is another week", UserSettings{MaxPerWeek: 2}, "UTC",
+			booked("2026-03-02T09:00:00Z", "2026-03-08T20:00:00Z"), "2026-03-01T23:00:00Z", ""},
+		// 01:00 on Monday in Tokyo, so it counts towards the week after
+		{"weeks follow the host's zone", UserSettings{MaxPerWeek: 1}, "Asia/Tokyo",
+			booked("2026-03-08T16:00:00Z"), "2026-03-10T00:00:00Z", codeWeeklyCap},
+		{"local sunday stays in its week", UserSettings{MaxPerWeek: 1}, "Asia/Tokyo",
+			booked("2026-03-08T16:00:00Z"), "2026-03-08T10:00:00Z", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCapCounter(tt.settings, mustLoad(t, tt.zone), tt.bookings)
+			if code, msg := c.check(at(tt.start)); code != tt.want {
+				t.Errorf("check(%s) = %q (%s), want %q", tt.start, code, msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflictsWithBooking(t *testing.T) {
+	booking := func(span string, before, after int) Booking {
+		iv := spans(t, span)[0]
+		return Booking{StartAtUTC: iv.Start, EndAtUTC: iv.End, BufferBefore: before, BufferAfter: after}
+	}
+	tests := []struct {
+		name          string
+		slot          string
+		before, after time.Duration
+		booking       Booking
+		want          bool
+	}{
+		{"overlap", "10:30-11:30", 0, 0, booking("10:00-11:00", 0, 0), true},
+		{"back to back", "11:00-12:00", 0, 0, booking("10:00-11:00", 0, 0), false},
+		{"slot's buffer before", "11:00-12:00", 15 * time.Minute, 0, booking("10:00-11:00", 0, 0), true},
+		{"slot's buffer after", "09:00-10:00", 0, time.Minute, booking("10:00-11:00", 0, 0), true},
+		{"booking's buffer after", "11:00-12:00", 0, 0, booking("10:00-11:00", 0, 30), true},
+		{"clear of booking's buffer", "11:30-12:30", 0, 0, booking("10:00-11:00", 0, 30), false},
+		{"booking's buffer before", "09:00-10:00", 0, 0, booking("10:00-11:00", 15, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot := spans(t, tt.slot)[0]
+			if got := conflictsWithBooking(slot, tt.before, tt.after, []Booking{tt.booking}); got != tt.want {
+				t.Errorf("conflictsWithBooking(%s) = %v, want %v", tt.slot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSlotShape(t *testing.T) {
+	tests := []struct {
+		slotLen, increment int
+		want               slotShape
+	}{
+		{30, 0, slotShape{SlotLen: 30 * time.Minute, Step: 30 * time.Minute}},
+		{60, 15, slotShape{SlotLen: time.Hour, Step: 15 * time.Minute}},
+		{30, 45, slotShape{SlotLen: 30 * time.Minute, Step: 45 * time.Minute}},
+	}
+	for _, tt := range tests {
+		if got := newSlotShape(tt.slotLen, tt.increment); got != tt.want {
+			t.Errorf("newSlotShape(%d, %d) = %+v, want %+v", tt.slotLen, tt.increment, got, tt.want)
+		}
+	}
+}
+
+func TestSlotIncrements(t *testing.T) {
+	monday := clock(t, "00:00")
+	rule := func(start, end string, slotLen, increment int) AvailabilityRule {
+		return AvailabilityRule{ID: start, DayOfWeek: int(time.Monday), StartTime: start, EndTime: end,
+			SlotLengthMins: slotLen, SlotIncrement: increment, Timezone: "UTC", Available: true}
+	}
+	tests := []struct {
+		name     string
+		rules    []AvailabilityRule
+		duration int
+		want     string
+	}{
+		{"slot length as step", []AvailabilityRule{rule("09:00", "11:00", 30, 0)}, 0, "09:00,09:30,10:00,10:30"},
+		{"shorter increment", []AvailabilityRule{rule("09:00", "11:00", 60, 30)}, 0, "09:00,09:30,10:00"},
+		{"last slot must fit", []AvailabilityRule{rule("09:00", "10:30", 60, 15)}, 0, "09:00,09:15,09:30"},
+		{"longer increment", []AvailabilityRule{rule("09:00", "11:00", 30, 45)}, 0, "09:00,09:45,10:30"},
+		{"event type duration keeps the increment", []AvailabilityRule{rule("09:00", "11:00", 30, 30)}, 60, "09:00,09:30,10:00"},
+		{"slots shared by two shapes listed once", []AvailabilityRule{rule("09:00", "10:00", 30, 0), rule("09:00", "10:00", 30, 15)}, 0, "09:00,09:15,09:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows, err := buildWindows(tt.rules, nil, monday, monday.Add(24*time.Hour), tt.duration)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Join(slotStarts(windowSlots(windows, monday, monday.Add(24*time.Hour))), ",")
+			if got != tt.want {
+				t.Errorf("slots = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Organization roles stored in memberships.role. Owners and admins act as
+// RoleAdmin within their organization.
+const (
+	orgRoleOwner  = "owner"
+	orgRoleAdmin  = "admin"
+	orgRoleMember = "member"
+)
+
+var errNotMember = errors.New("not a member of the organization")
+
+// Organization is a tenant: a customer company whose users and data are kept
+// apart from every other organization's.
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"` // the caller's role, when listing their organizations
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// Membership places a user in an organization.
+type Membership struct {
+	OrganizationID string    `json:"organization_id"`
+	UserID         string    `json:"user_id"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at,omitempty"`
+}
+
+type tenantKey struct{}
+
+// withTenant returns ctx scoped to tenantID. Queries on tenant-scoped tables
+// filter by it, and match nothing in a context without a tenant; RequireTenant
+// keeps such requests off the routes reading them.
+func withTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+func tenantFrom(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id
+}
+
+// tenantArg is the argument for a `tenant_id=$n` filter; NULL without a tenant.
+func tenantArg(ctx context.Context) any {
+	return nullIfEmpty(tenantFrom(ctx))
+}
+
+// ScopeToTenant makes every connection cfg hands out carry the tenant of the
+// acquiring context in app.tenant_id, which row-level security matches rows
+// against. Connections acquired without a tenant see no tenant rows.
+func ScopeToTenant(cfg *pgxpool.Config) {
+	cfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		_, err := conn.Exec(ctx, `SELECT set_config('app.tenant_id', $1, false)`, tenantFrom(ctx))
+		return err == nil
+	}
+}
+
+type systemKey struct{}
+
+// asSystem marks ctx as work no tenant scopes: background jobs, calendar
+// webhooks and OAuth callbacks, each authorized by its own secret. Queries in
+// such a context run on App.System and see every tenant's rows.
+func asSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// db returns the pool for queries in ctx.
+func (a *App) db(ctx context.Context) *pgxpool.Pool {
+	if system, _ := ctx.Value(systemKey{}).(bool); system {
+		return a.System
+	}
+	return a.DB
+}
+
+// beginTenantTx starts a transaction that row-level security restricts to the
+// tenant of ctx.
+func (a *App) beginTenantTx(ctx context.Context) (pgx.Tx, error) {
+	tx, err := a.db(ctx).Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenant := tenantFrom(ctx); tenant != "" {
+		if _, err := tx.Exec(ctx, `SELECT set_config('app.tenant_id', $1, true)`, tenant); err != nil {
+			tx.Rollback(ctx)
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// RequireTenant rejects callers not acting in an organization, so tenant-scoped
+// routes never run unscoped.
+func RequireTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenantFrom(c.Request.Context()) == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "no organization: set tenant_id or belong to exactly one"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// membershipRole returns userID's role in orgID, or errNotMember.
+func (a *App) membershipRole(ctx context.Context, orgID, userID string) (string, error) {
+	var role string
+	err := a.db(ctx).QueryRow(ctx, `SELECT role FROM memberships WHERE organization_id::text=$1 AND user_id::text=$2`,
+		orgID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errNotMember
+	}
+	return role, err
+}
+
+// resolveTenant fills in p.Tenant and checks that p.Subject belongs to it. A
+// principal naming no tenant gets their only organization; one with none or
+// several stays unscoped and can reach no tenant data. Organization owners and
+// admins gain RoleAdmin.
+func (a *App) resolveTenant(ctx context.Context, p *Principal) error {
+	if p.Tenant == "" {
+		rows, err := a.db(ctx).Query(ctx, `SELECT organization_id FROM memberships WHERE user_id::text=$1 LIMIT 2`, p.Subject)
+		if err != nil {
+			return err
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		if len(ids) != 1 {
+			return nil
+		}
+		p.Tenant = ids[0]
+	}
+
+	role, err := a.membershipRole(ctx, p.Tenant, p.Subject)
+	if err != nil {
+		return err
+	}
+	if (role == orgRoleOwner || role == orgRoleAdmin) && !p.HasRole(RoleAdmin) {
+		p.Roles = append(p.Roles, RoleAdmin)
+	}
+	return nil
+}
+
+// CreateOrganization creates org with ownerID as its owner.
+func (a *App) CreateOrganization(ctx context.Context, org *Organization, ownerID string) error {
+	tx, err := a.db(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `INSERT INTO organizations (id, name, created_at, updated_at)
+	                        VALUES (gen_random_uuid(), $1, now(), now()) RETURNING id, created_at`,
+		org.Name).Scan(&org.ID, &org.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO memberships (organization_id, user_id, role, created_at)
+	                           VALUES ($1, $2, $3, now())`, org.ID, ownerID, orgRoleOwner); err != nil {
+		return err
+	}
+	org.Role = orgRoleOwner
+	return tx.Commit(ctx)
+}
+
+// ListUserOrganizations returns the organizations userID belongs to, with their role.
+func (a *App) ListUserOrganizations(ctx context.Context, userID string) ([]Organization, error) {
+	rows, err := a.db(ctx).Query(ctx, `SELECT o.id, o.name, m.role, o.created_at
+	                              FROM organizations o JOIN memberships m ON m.organization_id = o.id
+	                              WHERE m.user_id::text=$1 ORDER BY o.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Organization
+	for rows.Next() {
+		var o Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.Role, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// ListMemberships returns orgID's members.
+func (a *App) ListMemberships(ctx context.Context, orgID string) ([]Membership, error) {
+	rows, err := a.db(ctx).Query(ctx, `SELECT organization_id, user_id, role, created_at FROM memberships
+	                              WHERE organization_id=$1 ORDER BY created_at, user_id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Membership
+	for rows.Next() {
+		var m Membership
+		if err := rows.Scan(&m.OrganizationID, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// UpsertMembership adds a member or changes their role.
+func (a *App) UpsertMembership(ctx context.Context, m *Membership) error {
+	q := `INSERT INTO memberships (organization_id, user_id, role, created_at)
+          VALUES ($1, $2, $3, now())
+          ON CONFLICT (organization_id, user_id) DO UPDATE SET role=EXCLUDED.role
+          RETURNING created_at`
+	return a.db(ctx).QueryRow(ctx, q, m.OrganizationID, m.UserID, m.Role).Scan(&m.CreatedAt)
+}
+
+// DeleteMembership removes a member, returning errNotMember when there is none.
+func (a *App) DeleteMembership(ctx context.Context, orgID, userID string) error {
+	tag, err := a.db(ctx).Exec(ctx, `DELETE FROM memberships WHERE organization_id=$1 AND user_id=$2`, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errNotMember
+	}
+	return nil
+}
+
+// countOwners returns how many owners orgID has.
+func (a *App) countOwners(ctx context.Context, orgID string) (int, error) {
+	var n int
+	err := a.db(ctx).QueryRow(ctx, `SELECT count(*) FROM memberships WHERE organization_id=$1 AND role=$2`,
+		orgID, orgRoleOwner).Scan(&n)
+	return n, err
+}
+
+// requireOrgRole responds 403 unless the caller holds one of roles in :org_id,
+// returning their role.
+func (a *App) requireOrgRole(c *gin.Context, roles ...string) (string, bool) {
+	p, ok := CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return "", false
+	}
+	role, err := a.membershipRole(c.Request.Context(), c.Param("org_id"), p.Subject)
+	if errors.Is(err, errNotMember) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return "", false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	for _, r := range roles {
+		if r == role {
+			return role, true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	return "", false
+}
+
+// POST /organizations
+// The caller becomes the new organization's owner.
+func (a *App) CreateOrganizationHandler(c *gin.Context) {
+	p, ok := CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org := &Organization{Name: strings.TrimSpace(req.Name)}
+	err := a.CreateOrganization(c.Request.Context(), org, p.Subject)
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "create a user profile before an organization"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, org)
+}
+
+// GET /organizations
+func (a *App) ListOrganizationsHandler(c *gin.Context) {
+	p, ok := CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	orgs, err := a.ListUserOrganizations(c.Request.Context(), p.Subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, orgs)
+}
+
+// GET /organizations/:org_id/members
+func (a *App) ListMembershipsHandler(c *gin.Context) {
+	if _, ok := a.requireOrgRole(c, orgRoleOwner, orgRoleAdmin, orgRoleMember); !ok {
+		return
+	}
+	members, err := a.ListMemberships(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// PUT /organizations/:org_id/members/:user_id
+// Adds a member or changes their role. Only owners may grant or revoke ownership.
+func (a *App) UpsertMembershipHandler(c *gin.Context) {
+	callerRole, ok := a.requireOrgRole(c, orgRoleOwner, orgRoleAdmin)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = orgRoleMember
+	}
+	switch req.Role {
+	case orgRoleOwner, orgRoleAdmin, orgRoleMember:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be owner, admin or member"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	orgID, userID := c.Param("org_id"), c.Param("user_id")
+	current, err := a.membershipRole(ctx, orgID, userID)
+	if err != nil && !errors.Is(err, errNotMember) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Role == orgRoleOwner || current == orgRoleOwner) && callerRole != orgRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only owners can change ownership"})
+		return
+	}
+	if current == orgRoleOwner && req.Role != orgRoleOwner {
+		if n, err := a.countOwners(ctx, orgID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		} else if n <= 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "organization must keep an owner"})
+			return
+		}
+	}
+
+	m := &Membership{OrganizationID: orgID, UserID: userID, Role: req.Role}
+	err = a.UpsertMembership(ctx, m)
+	if isForeignKeyViolation(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": errUserNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// DELETE /organizations/:org_id/members/:user_id
+func (a *App) DeleteMembershipHandler(c *gin.Context) {
+	callerRole, ok := a.requireOrgRole(c, orgRoleOwner, orgRoleAdmin)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	orgID, userID := c.Param("org_id"), c.Param("user_id")
+	current, err := a.membershipRole(ctx, orgID, userID)
+	if errors.Is(err, errNotMember) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "membership not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if current == orgRoleOwner {
+		if callerRole != orgRoleOwner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only owners can change ownership"})
+			return
+		}
+		if n, err := a.countOwners(ctx, orgID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		} else if n <= 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "organization must keep an owner"})
+			return
+		}
+	}
+
+	if err := a.DeleteMembership(ctx, orgID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TestRowLevelSecurityFailsClosed checks the policies as a role without
+// BYPASSRLS, inside a transaction that is rolled back along with the role.
+func TestRowLevelSecurityFailsClosed(t *testing.T) {
+	a := testApp(t)
+	day := bookingDay()
+	ctxA, hostA := seedHost(t, a, day)
+	ctxB, hostB := seedHost(t, a, day)
+	for _, s := range []struct {
+		ctx    context.Context
+		userID string
+	}{{ctxA, hostA}, {ctxB, hostB}} {
+		if err := a.UpsertUserSettings(s.ctx, &UserSettings{UserID: s.userID, Timezone: "UTC"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+
+	raw := make([]byte, 6)
+	if _, err := rand.Read(raw); err != nil {
+		t.Fatal(err)
+	}
+	role := "test_rls_" + hex.EncodeToString(raw)
+	var schema string
+	if err := tx.QueryRow(ctx, `SELECT current_schema()`).Scan(&schema); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{
+		`CREATE ROLE ` + role + ` NOLOGIN NOBYPASSRLS`,
+		`GRANT USAGE ON SCHEMA ` + schema + ` TO ` + role,
+		`GRANT SELECT ON ALL TABLES IN SCHEMA ` + schema + ` TO ` + role,
+		`SET LOCAL ROLE ` + role,
+	} {
+		if _, err := tx.Exec(ctx, q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		if err := tx.QueryRow(ctx, `SELECT count(*) FROM `+table).Scan(&n); err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		return n
+	}
+	setTenant := func(id string) {
+		t.Helper()
+		if _, err := tx.Exec(ctx, `SELECT set_config('app.tenant_id', $1, true)`, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, table := range []string{"availability_rules", "user_settings"} {
+		if n := count(table); n != 0 {
+			t.Errorf("%s without a tenant: %d rows visible, want none", table, n)
+		}
+	}
+	setTenant("")
+	if n := count("availability_rules"); n != 0 {
+		t.Errorf("availability_rules with an empty tenant: %d rows visible, want none", n)
+	}
+
+	setTenant(tenantFrom(ctxA))
+	if n := count("availability_rules"); n != 1 {
+		t.Errorf("availability_rules in tenant A: %d rows visible, want 1", n)
+	}
+	rows, err := tx.Query(ctx, `SELECT user_id::text FROM user_settings`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owners, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owners) != 1 || owners[0] != hostA {
+		t.Errorf("user_settings in tenant A belong to %v, want only host A %s", owners, hostA)
+	}
+}
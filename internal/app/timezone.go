@@ -0,0 +1,96 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLayout is the wire format for calendar dates (YYYY-MM-DD).
+const dateLayout = "2006-01-02"
+
+// loadTimezone validates an IANA timezone name against the tz database.
+// An empty name means UTC.
+func loadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+	// "Local" is accepted by time.LoadLocation but depends on the host, so reject it
+	if name == "Local" {
+		return nil, fmt.Errorf("invalid timezone: %s", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", name)
+	}
+	return loc, nil
+}
+
+// localDates returns the calendar dates (as UTC midnights) that [fromUTC, toUTC]
+// touches when viewed from loc.
+func localDates(fromUTC, toUTC time.Time, loc *time.Location) []time.Time {
+	fy, fm, fd := fromUTC.In(loc).Date()
+	ty, tm, td := toUTC.In(loc).Date()
+	first := time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)
+	last := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC)
+
+	var out []time.Time
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		out = append(out, d)
+	}
+	return out
+}
+
+// wallClock resolves a local calendar date and time of day in loc to an instant.
+// A time that falls into a DST gap is shifted forward by the size of the gap
+// (02:30 on a spring-forward day becomes 03:30), and a time that occurs twice
+// in a DST overlap resolves to the earlier instant.
+func wallClock(date, tod time.Time, loc *time.Location) time.Time {
+	y, m, d := date.Date()
+	naive := time.Date(y, m, d, tod.Hour(), tod.Minute(), 0, 0, time.UTC)
+
+	var (
+		best  time.Time
+		found bool
+	)
+	// Transitions are never closer than a day apart, so the offsets in force
+	// around the date are the only candidates.
+	for _, probe := range []time.Time{naive.Add(-24 * time.Hour), naive, naive.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		cand := naive.Add(-time.Duration(offset) * time.Second)
+		if sameWallClock(cand.In(loc), naive) && (!found || cand.Before(best)) {
+			best = cand
+			found = true
+		}
+	}
+	if found {
+		return best
+	}
+
+	// DST gap: applying the offset from before the transition lands past it
+	_, offset := naive.Add(-24 * time.Hour).In(loc).Zone()
+	return naive.Add(-time.Duration(offset) * time.Second)
+}
+
+func sameWallClock(local, naive time.Time) bool {
+	ly, lm, ld := local.Date()
+	ny, nm, nd := naive.Date()
+	return ly == ny && lm == nm && ld == nd &&
+		local.Hour() == naive.Hour() && local.Minute() == naive.Minute()
+}
+
+// localDayBounds returns the start and end of the local day in loc containing t.
+func localDayBounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	y, m, d := t.In(loc).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return wallClock(day, time.Time{}, loc), wallClock(day.AddDate(0, 0, 1), time.Time{}, loc)
+}
+
+// localWeekBounds returns the start and end of the Monday-based local week in loc containing t.
+func localWeekBounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	y, m, d := t.In(loc).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	monday := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	return wallClock(monday, time.Time{}, loc), wallClock(monday.AddDate(0, 0, 7), time.Time{}, loc)
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestWallClock(t *testing.T) {
+	tests := []struct {
+		name, zone, date, tod string
+		want                  string // RFC 3339 in UTC
+	}{
+		{"UTC", "UTC", "2026-07-01", "09:00", "2026-07-01T09:00:00Z"},
+		{"summer time", "America/New_York", "2026-07-01", "09:00", "2026-07-01T13:00:00Z"},
+		{"before the gap", "America/New_York", "2026-03-08", "01:30", "2026-03-08T06:30:00Z"},
+		{"in the gap moves forward", "America/New_York", "2026-03-08", "02:30", "2026-03-08T07:30:00Z"},
+		{"after the gap", "America/New_York", "2026-03-08", "03:30", "2026-03-08T07:30:00Z"},
+		{"midnight on the gap day", "America/New_York", "2026-03-08", "00:00", "2026-03-08T05:00:00Z"},
+		{"in the overlap takes the earlier", "America/New_York", "2026-11-01", "01:30", "2026-11-01T05:30:00Z"},
+		{"after the overlap", "America/New_York", "2026-11-01", "02:00", "2026-11-01T07:00:00Z"},
+		{"gap at 01:00", "Europe/London", "2026-03-29", "01:30", "2026-03-29T01:30:00Z"},
+		{"overlap at 01:00", "Europe/London", "2026-10-25", "01:30", "2026-10-25T00:30:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := time.Parse(dateLayout, tt.date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tod, err := parseHHMM(tt.tod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := wallClock(date, tod, mustLoad(t, tt.zone))
+			if got.UTC().Format(time.RFC3339) != tt.want {
+				t.Errorf("wallClock(%s %s %s) = %s, want %s", tt.date, tt.tod, tt.zone, got.UTC().Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalWeekBounds(t *testing.T) {
+	tests := []struct {
+		name, zone, at   string
+		wantFrom, wantTo string
+	}{
+		{"midweek", "UTC", "2026-03-04T12:00:00Z", "2026-03-02T00:00:00Z", "2026-03-09T00:00:00Z"},
+		{"monday starts the week", "UTC", "2026-03-02T00:00:00Z", "2026-03-02T00:00:00Z", "2026-03-09T00:00:00Z"},
+		{"sunday ends the week", "UTC", "2026-03-08T23:59:00Z", "2026-03-02T00:00:00Z", "2026-03-09T00:00:00Z"},
+		// Sunday evening locally is already Monday in UTC
+		{"local sunday", "America/New_York", "2026-03-09T03:00:00Z", "2026-03-02T05:00:00Z", "2026-03-09T04:00:00Z"},
+		// Monday morning locally is still Sunday in UTC
+		{"local monday", "Asia/Tokyo", "2026-03-08T16:00:00Z", "2026-03-08T15:00:00Z", "2026-03-15T15:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, to := localWeekBounds(at, mustLoad(t, tt.zone))
+			if got := from.UTC().Format(time.RFC3339); got != tt.wantFrom {
+				t.Errorf("week of %s starts %s, want %s", tt.at, got, tt.wantFrom)
+			}
+			if got := to.UTC().Format(time.RFC3339); got != tt.wantTo {
+				t.Errorf("week of %s ends %s, want %s", tt.at, got, tt.wantTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+// uuidPattern matches the textual form of a UUID, which user IDs must take.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// localePattern matches BCP 47 language tags such as "en" or "pt-BR".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// User is a person who owns availability and bookings. Timezone is the default
+// for their rules, overrides and settings; Slug is their public handle.
+type User struct {
+	ID          string    `json:"id"`
+	DisplayName string    `json:"display_name"`
+	Email       string    `json:"email,omitempty"`
+	Timezone    string    `json:"timezone"`
+	Locale      string    `json:"locale"`
+	Slug        string    `json:"slug,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// validateUser normalizes the profile fields, defaulting timezone to UTC and
+// locale to "en".
+func validateUser(u *User) error {
+	u.DisplayName = strings.TrimSpace(u.DisplayName)
+	u.Email = strings.TrimSpace(u.Email)
+	u.Slug = strings.ToLower(strings.TrimSpace(u.Slug))
+	u.Locale = strings.TrimSpace(u.Locale)
+
+	if u.Email != "" {
+		if addr, err := mail.ParseAddress(u.Email); err != nil || addr.Address != u.Email {
+			return fmt.Errorf("invalid email: %s", u.Email)
+		}
+	}
+	if u.Slug != "" && !slugPattern.MatchString(u.Slug) {
+		return fmt.Errorf("slug must be lowercase letters, digits and dashes")
+	}
+	if u.Locale == "" {
+		u.Locale = "en"
+	}
+	if !localePattern.MatchString(u.Locale) {
+		return fmt.Errorf("invalid locale: %s", u.Locale)
+	}
+
+	loc, err := loadTimezone(u.Timezone)
+	if err != nil {
+		return err
+	}
+	u.Timezone = loc.String()
+
+	return nil
+}
+
+// userConflict describes the unique constraint a user write violated.
+func userConflict(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "ux_users_slug" {
+		return "slug already in use"
+	}
+	return "user already exists"
+}
+
+// GetUser returns userID's profile, or errUserNotFound.
+func (a *App) GetUser(ctx context.Context, userID string) (User, error) {
+	q := `SELECT id, display_name, COALESCE(email,''), timezone, locale, COALESCE(slug,''), created_at, updated_at
+	      FROM users WHERE id=$1`
+	var u User
+	err := a.db(ctx).QueryRow(ctx, q, userID).Scan(&u.ID, &u.DisplayName, &u.Email, &u.Timezone, &u.Locale,
+		&u.Slug, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return u, errUserNotFound
+	}
+	return u, err
+}
+
+// userTimezone returns userID's default timezone, or errUserNotFound.
+func (a *App) userTimezone(ctx context.Context, userID string) (string, error) {
+	var tz string
+	err := a.db(ctx).QueryRow(ctx, `SELECT timezone FROM users WHERE id=$1`, userID).Scan(&tz)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errUserNotFound
+	}
+	return tz, err
+}
+
+// CreateUser stores u, generating its ID when empty. A non-empty tenantID makes
+// the user a member of that organization.
+func (a *App) CreateUser(ctx context.Context, u *User, tenantID string) error {
+	tx, err := a.db(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `INSERT INTO users (id, display_name, email, timezone, locale, slug, created_at, updated_at)
+          VALUES (COALESCE($1::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, now(), now())
+          RETURNING id, created_at, updated_at`
+	err = tx.QueryRow(ctx, q, nullIfEmpty(u.ID), u.DisplayName, nullIfEmpty(u.Email), u.Timezone, u.Locale,
+		nullIfEmpty(u.Slug)).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if tenantID != "" {
+		if _, err := tx.Exec(ctx, `INSERT INTO memberships (organization_id, user_id, role, created_at)
+		                           VALUES ($1, $2, $3, now())`, tenantID, u.ID, orgRoleMember); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateUser replaces u's profile fields, returning errUserNotFound when there is no such user.
+func (a *App) UpdateUser(ctx context.Context, u *User) error {
+	q := `UPDATE users SET display_name=$1, email=$2, timezone=$3, locale=$4, slug=$5, updated_at=now()
+          WHERE id=$6 RETURNING created_at, updated_at`
+	err := a.db(ctx).QueryRow(ctx, q, u.DisplayName, nullIfEmpty(u.Email), u.Timezone, u.Locale, nullIfEmpty(u.Slug),
+		u.ID).Scan(&u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errUserNotFound
+	}
+	return err
+}
+
+// DeleteUser removes userID together with everything they own.
+func (a *App) DeleteUser(ctx context.Context, userID string) error {
+	tag, err := a.db(ctx).Exec(ctx, `DELETE FROM users WHERE id=$1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errUserNotFound
+	}
+	return nil
+}
+
+// soleOwner reports whether userID is the only owner of an organization that
+// has other members.
+func (a *App) soleOwner(ctx context.Context, userID string) (bool, error) {
+	q := `SELECT EXISTS (
+	          SELECT 1 FROM memberships m
+	          WHERE m.user_id=$1 AND m.role=$2
+	            AND EXISTS (SELECT 1 FROM memberships o
+	                        WHERE o.organization_id=m.organization_id AND o.user_id<>m.user_id)
+	            AND NOT EXISTS (SELECT 1 FROM memberships o
+	                            WHERE o.organization_id=m.organization_id AND o.role=$2 AND o.user_id<>m.user_id))`
+	var sole bool
+	err := a.db(ctx).QueryRow(ctx, q, userID, orgRoleOwner).Scan(&sole)
+	return sole, err
+}
+
+// belongsElsewhere reports whether userID is a member of an organization other than orgID.
+func (a *App) belongsElsewhere(ctx context.Context, userID, orgID string) (bool, error) {
+	var other bool
+	err := a.db(ctx).QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM memberships WHERE user_id=$1 AND organization_id<>$2)`,
+		userID, orgID).Scan(&other)
+	return other, err
+}
+
+// POST /users
+// Callers create their own profile, with their subject as its ID, so the
+// subject must be a UUID. Admins may also create other users, who join the
+// admin's organization.
+func (a *App) CreateUserHandler(c *gin.Context) {
+	p, ok := CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	var payload User
+	if err := c.BindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	admin := p.Tenant != "" && p.HasRole(RoleAdmin)
+	if payload.ID == "" && !admin {
+		payload.ID = p.Subject
+	}
+	if payload.ID != p.Subject && !admin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	if payload.ID != "" && !uuidPattern.MatchString(payload.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user id must be a UUID"})
+		return
+	}
+
+	if err := validateUser(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tenantID string
+	if payload.ID != p.Subject {
+		tenantID = p.Tenant
+	}
+	err := a.CreateUser(c.Request.Context(), &payload, tenantID)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": userConflict(err)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, payload)
+}
+
+// GET /users/:id
+func (a *App) GetUserHandler(c *gin.Context) {
+	u, err := a.GetUser(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// PUT /users/:id
+func (a *App) UpdateUserHandler(c *gin.Context) {
+	var payload User
+	if err := c.BindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	payload.ID = c.Param("id")
+
+	if err := validateUser(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := a.UpdateUser(c.Request.Context(), &payload)
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": userConflict(err)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payload)
+}
+
+// DELETE /users/:id
+// Removes the user with their availability, bookings, calendars and
+// memberships. Admins may only delete users who belong to no other
+// organization, and an organization's last owner can't leave its other
+// members behind.
+func (a *App) DeleteUserHandler(c *gin.Context) {
+	p, ok := CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+	ctx := c.Request.Context()
+	userID := c.Param("id")
+
+	if userID != p.Subject {
+		other, err := a.belongsElsewhere(ctx, userID, p.Tenant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if other {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user belongs to other organizations"})
+			return
+		}
+	}
+	sole, err := a.soleOwner(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if sole {
+		c.JSON(http.StatusConflict, gin.H{"error": "user is the only owner of an organization with other members"})
+		return
+	}
+
+	err = a.DeleteUser(ctx, userID)
+	if errors.Is(err, errUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,3 @@
+-- Re-introduce a per-rule IANA timezone (e.g. "America/New_York")
+-- start_time/end_time are wall-clock times in this zone; existing rules were stored in UTC
+ALTER TABLE availability_rules ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
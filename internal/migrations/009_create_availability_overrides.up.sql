@@ -0,0 +1,20 @@
+-- Date-specific availability overrides
+-- A blocked override removes all availability on override_date; otherwise the
+-- override's window replaces the weekly rules for that date
+CREATE TABLE IF NOT EXISTS availability_overrides (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    override_date DATE NOT NULL,
+    blocked BOOLEAN NOT NULL DEFAULT FALSE,
+    start_time TIME,
+    end_time TIME,
+    slot_length_minutes INT CHECK (slot_length_minutes > 0),
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    title TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    CHECK (blocked OR (start_time IS NOT NULL AND end_time IS NOT NULL AND slot_length_minutes IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS ix_availability_overrides_user_date
+    ON availability_overrides (user_id, override_date);
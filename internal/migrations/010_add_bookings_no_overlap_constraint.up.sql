@@ -0,0 +1,10 @@
+-- Reject overlapping confirmed bookings for the same user, even under concurrent inserts
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap
+    EXCLUDE USING gist (user_id WITH =, tstzrange(start_at_utc, end_at_utc) WITH &&)
+    WHERE (status = 'confirmed');
+
+-- Exact start-time matching is superseded by the exclusion constraint
+DROP INDEX IF EXISTS ux_bookings_user_start_confirmed;
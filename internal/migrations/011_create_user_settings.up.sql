@@ -0,0 +1,13 @@
+-- Per-user scheduling settings
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id UUID PRIMARY KEY,
+    buffer_before_minutes INT NOT NULL DEFAULT 0 CHECK (buffer_before_minutes >= 0 AND buffer_before_minutes <= 1440),
+    buffer_after_minutes INT NOT NULL DEFAULT 0 CHECK (buffer_after_minutes >= 0 AND buffer_after_minutes <= 1440),
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Buffers in force when a booking was made, so later settings changes don't move its padding
+ALTER TABLE bookings
+    ADD COLUMN buffer_before_minutes INT NOT NULL DEFAULT 0,
+    ADD COLUMN buffer_after_minutes INT NOT NULL DEFAULT 0;
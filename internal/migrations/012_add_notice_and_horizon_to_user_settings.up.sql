@@ -0,0 +1,4 @@
+-- Minimum scheduling notice and rolling booking horizon (0 disables either limit)
+ALTER TABLE user_settings
+    ADD COLUMN min_notice_minutes INT NOT NULL DEFAULT 0 CHECK (min_notice_minutes >= 0),
+    ADD COLUMN booking_horizon_days INT NOT NULL DEFAULT 0 CHECK (booking_horizon_days >= 0);
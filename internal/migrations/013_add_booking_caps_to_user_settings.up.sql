@@ -0,0 +1,6 @@
+-- Daily and weekly booking caps per host (0 disables a cap)
+-- timezone defines where a host's days and weeks (Monday-based) begin
+ALTER TABLE user_settings
+    ADD COLUMN max_bookings_per_day INT NOT NULL DEFAULT 0 CHECK (max_bookings_per_day >= 0),
+    ADD COLUMN max_bookings_per_week INT NOT NULL DEFAULT 0 CHECK (max_bookings_per_week >= 0),
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
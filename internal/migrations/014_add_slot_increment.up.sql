@@ -0,0 +1,6 @@
+-- Start-time increment independent of slot length (NULL = step by slot_length_minutes)
+ALTER TABLE availability_rules
+    ADD COLUMN slot_increment_minutes INT CHECK (slot_increment_minutes > 0);
+
+ALTER TABLE availability_overrides
+    ADD COLUMN slot_increment_minutes INT CHECK (slot_increment_minutes > 0);
@@ -0,0 +1,24 @@
+-- Bookable meeting kinds per host, each with its own duration and buffers
+CREATE TABLE IF NOT EXISTS event_types (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    duration_minutes INT NOT NULL CHECK (duration_minutes > 0),
+    -- NULL buffers fall back to user_settings
+    buffer_before_minutes INT CHECK (buffer_before_minutes >= 0 AND buffer_before_minutes <= 1440),
+    buffer_after_minutes INT CHECK (buffer_after_minutes >= 0 AND buffer_after_minutes <= 1440),
+    location TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    CONSTRAINT uniq_event_type_user_slug UNIQUE (user_id, slug)
+);
+
+-- Optional restriction of an event type to a subset of the host's availability rules
+CREATE TABLE IF NOT EXISTS event_type_rules (
+    event_type_id UUID NOT NULL REFERENCES event_types(id) ON DELETE CASCADE,
+    rule_id UUID NOT NULL REFERENCES availability_rules(id) ON DELETE CASCADE,
+    PRIMARY KEY (event_type_id, rule_id)
+);
+
+ALTER TABLE bookings ADD COLUMN event_type_id UUID REFERENCES event_types(id) ON DELETE SET NULL;
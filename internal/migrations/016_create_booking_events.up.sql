@@ -0,0 +1,23 @@
+-- Booking history: every status transition and every move to new times
+ALTER TABLE bookings ADD COLUMN updated_at TIMESTAMPTZ DEFAULT now();
+
+CREATE TABLE IF NOT EXISTS booking_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    action TEXT NOT NULL CHECK (action IN ('created', 'rescheduled', 'cancelled')),
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    previous_start_at_utc TIMESTAMPTZ,
+    previous_end_at_utc TIMESTAMPTZ,
+    start_at_utc TIMESTAMPTZ NOT NULL,
+    end_at_utc TIMESTAMPTZ NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ix_booking_events_booking
+    ON booking_events (booking_id, created_at);
+
+-- Existing bookings start their history at creation
+INSERT INTO booking_events (booking_id, action, to_status, start_at_utc, end_at_utc, created_at)
+SELECT id, 'created', 'confirmed', start_at_utc, end_at_utc, created_at FROM bookings;
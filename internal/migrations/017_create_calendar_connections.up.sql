@@ -0,0 +1,14 @@
+-- Server-side OAuth tokens per user and calendar provider
+-- access_token/refresh_token are AES-256-GCM sealed with TOKEN_ENCRYPTION_KEY
+CREATE TABLE IF NOT EXISTS calendar_connections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL,
+    access_token BYTEA NOT NULL,
+    refresh_token BYTEA,
+    token_type TEXT,
+    expiry TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    CONSTRAINT uniq_calendar_connection_user_provider UNIQUE (user_id, provider)
+);
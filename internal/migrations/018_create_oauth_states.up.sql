@@ -0,0 +1,13 @@
+-- Pending OAuth authorizations, bound to the scheduler user who started them
+-- state_hash is sha256 of the random state handed to the provider; code_verifier
+-- is the PKCE verifier sealed with TOKEN_ENCRYPTION_KEY. Rows are single-use.
+CREATE TABLE IF NOT EXISTS oauth_states (
+    state_hash BYTEA PRIMARY KEY,
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL,
+    code_verifier BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ix_oauth_states_expires_at ON oauth_states (expires_at);
@@ -0,0 +1,4 @@
+-- Event created on the host's calendar for this booking (GOOGLE_CALENDAR_WRITE)
+ALTER TABLE bookings
+    ADD COLUMN external_calendar_id TEXT,
+    ADD COLUMN external_event_id TEXT;
@@ -0,0 +1,42 @@
+-- Push-notification sync for external calendars
+
+-- Active watch channels; token_hash is sha256 of the secret echoed back in X-Goog-Channel-Token
+CREATE TABLE IF NOT EXISTS calendar_watch_channels (
+    id TEXT PRIMARY KEY,
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL,
+    calendar_id TEXT NOT NULL,
+    resource_id TEXT NOT NULL,
+    token_hash BYTEA NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ix_calendar_watch_channels_expires_at ON calendar_watch_channels (expires_at);
+
+-- Incremental sync position per calendar
+CREATE TABLE IF NOT EXISTS calendar_sync_state (
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL,
+    calendar_id TEXT NOT NULL,
+    sync_token TEXT,
+    last_synced_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, provider, calendar_id)
+);
+
+-- Local copy of external events, reduced to what busy-time computation needs
+CREATE TABLE IF NOT EXISTS external_events (
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL,
+    calendar_id TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    start_at_utc TIMESTAMPTZ NOT NULL,
+    end_at_utc TIMESTAMPTZ NOT NULL,
+    all_day BOOLEAN NOT NULL DEFAULT FALSE,
+    transparent BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (user_id, provider, calendar_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS ix_external_events_user_range
+    ON external_events (user_id, start_at_utc, end_at_utc);
@@ -0,0 +1,14 @@
+-- Per-user choice of which connected calendars block time and which receives new events
+CREATE TABLE IF NOT EXISTS calendar_selections (
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL,
+    calendar_id TEXT NOT NULL,
+    conflict BOOLEAN NOT NULL DEFAULT FALSE,
+    target BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (user_id, provider, calendar_id)
+);
+
+-- At most one write target per user and provider
+CREATE UNIQUE INDEX IF NOT EXISTS ux_calendar_selections_target
+    ON calendar_selections (user_id, provider) WHERE target;
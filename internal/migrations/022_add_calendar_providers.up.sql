@@ -0,0 +1,15 @@
+-- Calendar providers beyond Google
+
+-- Server address for providers without a fixed endpoint (CalDAV). For CalDAV,
+-- access_token holds the sealed username and password.
+ALTER TABLE calendar_connections ADD COLUMN IF NOT EXISTS server_url TEXT;
+
+-- Which provider holds a booking's mirrored event
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS external_provider TEXT;
+UPDATE bookings SET external_provider = 'google'
+    WHERE external_event_id IS NOT NULL AND external_provider IS NULL;
+
+-- New events go to a single calendar per user, whichever provider it belongs to
+DROP INDEX IF EXISTS ux_calendar_selections_target;
+CREATE UNIQUE INDEX IF NOT EXISTS ux_calendar_selections_target_user
+    ON calendar_selections (user_id) WHERE target;
@@ -0,0 +1,31 @@
+-- Read-only ICS feed subscriptions used as busy sources
+
+-- url is sealed because secret feed addresses grant read access to the calendar
+CREATE TABLE IF NOT EXISTS ics_feeds (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    name TEXT,
+    url BYTEA NOT NULL,
+    url_host TEXT NOT NULL,
+    etag TEXT,
+    last_modified TEXT,
+    last_fetched_at TIMESTAMPTZ,
+    last_success_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ix_ics_feeds_user_id ON ics_feeds (user_id);
+CREATE INDEX IF NOT EXISTS ix_ics_feeds_last_fetched_at ON ics_feeds (last_fetched_at);
+
+-- Expanded busy intervals from the last successful fetch of each feed
+CREATE TABLE IF NOT EXISTS ics_feed_busy (
+    feed_id UUID NOT NULL REFERENCES ics_feeds (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    start_at_utc TIMESTAMPTZ NOT NULL,
+    end_at_utc TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ix_ics_feed_busy_feed_id ON ics_feed_busy (feed_id);
+CREATE INDEX IF NOT EXISTS ix_ics_feed_busy_user_range ON ics_feed_busy (user_id, start_at_utc, end_at_utc);
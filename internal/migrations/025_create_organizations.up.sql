@@ -0,0 +1,67 @@
+-- Organizations (tenants) and their members
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS ix_memberships_user_id ON memberships (user_id);
+
+-- Existing data moves into a default organization
+INSERT INTO organizations (id, name)
+    VALUES ('00000000-0000-0000-0000-000000000001', 'Default')
+    ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO memberships (organization_id, user_id)
+    SELECT '00000000-0000-0000-0000-000000000001', user_id FROM (
+        SELECT user_id FROM availability_rules
+        UNION SELECT user_id FROM bookings
+        UNION SELECT user_id FROM availability_overrides
+        UNION SELECT user_id FROM user_settings
+        UNION SELECT user_id FROM event_types
+        UNION SELECT user_id FROM calendar_connections
+        UNION SELECT owner_id FROM api_keys
+    ) AS existing
+    ON CONFLICT DO NOTHING;
+
+ALTER TABLE availability_rules ADD COLUMN IF NOT EXISTS tenant_id UUID REFERENCES organizations(id);
+UPDATE availability_rules SET tenant_id = '00000000-0000-0000-0000-000000000001' WHERE tenant_id IS NULL;
+ALTER TABLE availability_rules ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS ix_availability_rules_tenant_user ON availability_rules (tenant_id, user_id);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS tenant_id UUID REFERENCES organizations(id);
+UPDATE bookings SET tenant_id = '00000000-0000-0000-0000-000000000001' WHERE tenant_id IS NULL;
+ALTER TABLE bookings ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS ix_bookings_tenant_user ON bookings (tenant_id, user_id);
+
+-- API keys act within one organization
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+UPDATE api_keys SET tenant_id = '00000000-0000-0000-0000-000000000001' WHERE tenant_id IS NULL;
+ALTER TABLE api_keys ALTER COLUMN tenant_id SET NOT NULL;
+
+-- Row-level security backs the tenant filters in queries. Transactions opened
+-- for a request set app.tenant_id; sessions without it (migrations, background
+-- jobs) are not restricted.
+ALTER TABLE availability_rules ENABLE ROW LEVEL SECURITY;
+ALTER TABLE availability_rules FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON availability_rules;
+CREATE POLICY tenant_isolation ON availability_rules
+    USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id::text))
+    WITH CHECK (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id::text));
+
+ALTER TABLE bookings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE bookings FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON bookings;
+CREATE POLICY tenant_isolation ON bookings
+    USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id::text))
+    WITH CHECK (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id::text));
@@ -0,0 +1,69 @@
+-- Users, previously only UUIDs scattered across tables
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    display_name TEXT NOT NULL DEFAULT '',
+    email TEXT,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    locale TEXT NOT NULL DEFAULT 'en',
+    slug TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+-- Public handle used in booking links
+CREATE UNIQUE INDEX IF NOT EXISTS ux_users_slug ON users (slug) WHERE slug IS NOT NULL;
+
+-- Every user ID already in use becomes a user, keeping their settings' timezone
+INSERT INTO users (id)
+    SELECT user_id FROM (
+        SELECT user_id FROM availability_rules
+        UNION SELECT user_id FROM bookings
+        UNION SELECT user_id FROM availability_overrides
+        UNION SELECT user_id FROM user_settings
+        UNION SELECT user_id FROM event_types
+        UNION SELECT user_id FROM calendar_connections
+        UNION SELECT user_id FROM oauth_states
+        UNION SELECT user_id FROM calendar_watch_channels
+        UNION SELECT user_id FROM calendar_sync_state
+        UNION SELECT user_id FROM external_events
+        UNION SELECT user_id FROM calendar_selections
+        UNION SELECT user_id FROM ics_feeds
+        UNION SELECT user_id FROM ics_feed_busy
+        UNION SELECT user_id FROM memberships
+        UNION SELECT owner_id FROM api_keys
+    ) AS existing
+    ON CONFLICT (id) DO NOTHING;
+
+UPDATE users u SET timezone = s.timezone FROM user_settings s WHERE s.user_id = u.id;
+
+-- Deleting a user removes everything they own
+ALTER TABLE availability_rules
+    ADD CONSTRAINT fk_availability_rules_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE bookings
+    ADD CONSTRAINT fk_bookings_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE availability_overrides
+    ADD CONSTRAINT fk_availability_overrides_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE user_settings
+    ADD CONSTRAINT fk_user_settings_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE event_types
+    ADD CONSTRAINT fk_event_types_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE calendar_connections
+    ADD CONSTRAINT fk_calendar_connections_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE oauth_states
+    ADD CONSTRAINT fk_oauth_states_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE calendar_watch_channels
+    ADD CONSTRAINT fk_calendar_watch_channels_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE calendar_sync_state
+    ADD CONSTRAINT fk_calendar_sync_state_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE external_events
+    ADD CONSTRAINT fk_external_events_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE calendar_selections
+    ADD CONSTRAINT fk_calendar_selections_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE ics_feeds
+    ADD CONSTRAINT fk_ics_feeds_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE ics_feed_busy
+    ADD CONSTRAINT fk_ics_feed_busy_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE memberships
+    ADD CONSTRAINT fk_memberships_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE api_keys
+    ADD CONSTRAINT fk_api_keys_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE;
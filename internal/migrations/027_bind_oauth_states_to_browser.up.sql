@@ -0,0 +1,6 @@
+-- Bind pending OAuth authorizations to the browser that started them.
+-- browser_hash is sha256 of a nonce kept in an HttpOnly cookie; pending rows
+-- from before have no cookie to match and are dropped.
+DELETE FROM oauth_states;
+
+ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS browser_hash BYTEA NOT NULL;
@@ -0,0 +1,3 @@
+-- The browser nonce is now set when the browser opens the start link, after
+-- the authorization was created, so pending rows have no browser_hash yet.
+ALTER TABLE oauth_states ALTER COLUMN browser_hash DROP NOT NULL;
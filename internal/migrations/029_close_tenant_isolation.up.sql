@@ -0,0 +1,132 @@
+-- Row-level security that fails closed. Requests set app.tenant_id on every
+-- connection they use; a session without it sees no tenant rows at all.
+-- Migrations and work no tenant scopes (background jobs, calendar webhooks,
+-- OAuth callbacks) connect as a separate role with BYPASSRLS, e.g.
+--   CREATE ROLE scheduler_system LOGIN BYPASSRLS;
+-- given as SYSTEM_DATABASE_URL, while DATABASE_URL uses a role without it.
+
+-- The organization the session acts in; NULL when none is set
+CREATE OR REPLACE FUNCTION current_tenant_id() RETURNS UUID
+    LANGUAGE sql STABLE
+    AS $$ SELECT NULLIF(current_setting('app.tenant_id', true), '')::uuid $$;
+
+-- Members of that organization, whose per-user data it may reach
+CREATE OR REPLACE FUNCTION current_tenant_members() RETURNS SETOF UUID
+    LANGUAGE sql STABLE
+    AS $$ SELECT user_id FROM memberships WHERE organization_id = current_tenant_id() $$;
+
+DROP POLICY IF EXISTS tenant_isolation ON availability_rules;
+CREATE POLICY tenant_isolation ON availability_rules
+    USING (tenant_id = current_tenant_id());
+
+DROP POLICY IF EXISTS tenant_isolation ON bookings;
+CREATE POLICY tenant_isolation ON bookings
+    USING (tenant_id = current_tenant_id());
+
+-- Overrides and event types belong to an organization like the rules they
+-- sit beside. Existing rows go to their user's first organization.
+ALTER TABLE availability_overrides ADD COLUMN IF NOT EXISTS tenant_id UUID REFERENCES organizations(id);
+UPDATE availability_overrides o SET tenant_id = COALESCE(
+        (SELECT organization_id FROM memberships m WHERE m.user_id = o.user_id
+         ORDER BY m.created_at, m.organization_id LIMIT 1),
+        '00000000-0000-0000-0000-000000000001')
+    WHERE tenant_id IS NULL;
+ALTER TABLE availability_overrides ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS ix_availability_overrides_tenant_user_date
+    ON availability_overrides (tenant_id, user_id, override_date);
+
+ALTER TABLE availability_overrides ENABLE ROW LEVEL SECURITY;
+ALTER TABLE availability_overrides FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON availability_overrides;
+CREATE POLICY tenant_isolation ON availability_overrides
+    USING (tenant_id = current_tenant_id());
+
+ALTER TABLE event_types ADD COLUMN IF NOT EXISTS tenant_id UUID REFERENCES organizations(id);
+UPDATE event_types e SET tenant_id = COALESCE(
+        (SELECT organization_id FROM memberships m WHERE m.user_id = e.user_id
+         ORDER BY m.created_at, m.organization_id LIMIT 1),
+        '00000000-0000-0000-0000-000000000001')
+    WHERE tenant_id IS NULL;
+ALTER TABLE event_types ALTER COLUMN tenant_id SET NOT NULL;
+-- a host may reuse a slug in each of their organizations
+ALTER TABLE event_types DROP CONSTRAINT IF EXISTS uniq_event_type_user_slug;
+ALTER TABLE event_types ADD CONSTRAINT uniq_event_type_tenant_user_slug UNIQUE (tenant_id, user_id, slug);
+
+ALTER TABLE event_types ENABLE ROW LEVEL SECURITY;
+ALTER TABLE event_types FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON event_types;
+CREATE POLICY tenant_isolation ON event_types
+    USING (tenant_id = current_tenant_id());
+
+-- Child rows follow their parent, whose own policy applies in the subquery
+ALTER TABLE event_type_rules ENABLE ROW LEVEL SECURITY;
+ALTER TABLE event_type_rules FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON event_type_rules;
+CREATE POLICY tenant_isolation ON event_type_rules
+    USING (event_type_id IN (SELECT id FROM event_types));
+
+ALTER TABLE booking_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE booking_events FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON booking_events;
+CREATE POLICY tenant_isolation ON booking_events
+    USING (booking_id IN (SELECT id FROM bookings));
+
+-- Settings, calendar connections and everything synced through them belong to
+-- the user rather than an organization: a host in two organizations has one
+-- Google account and one set of feeds. These stay without tenant_id and are
+-- reachable from any organization the user is a member of, and no other.
+ALTER TABLE user_settings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_settings FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON user_settings;
+CREATE POLICY tenant_isolation ON user_settings
+    USING (user_id IN (SELECT current_tenant_members()));
+
+ALTER TABLE calendar_connections ENABLE ROW LEVEL SECURITY;
+ALTER TABLE calendar_connections FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON calendar_connections;
+CREATE POLICY tenant_isolation ON calendar_connections
+    USING (user_id IN (SELECT current_tenant_members()));
+
+ALTER TABLE calendar_selections ENABLE ROW LEVEL SECURITY;
+ALTER TABLE calendar_selections FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON calendar_selections;
+CREATE POLICY tenant_isolation ON calendar_selections
+    USING (user_id IN (SELECT current_tenant_members()));
+
+ALTER TABLE calendar_watch_channels ENABLE ROW LEVEL SECURITY;
+ALTER TABLE calendar_watch_channels FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON calendar_watch_channels;
+CREATE POLICY tenant_isolation ON calendar_watch_channels
+    USING (user_id IN (SELECT current_tenant_members()));
+
+ALTER TABLE calendar_sync_state ENABLE ROW LEVEL SECURITY;
+ALTER TABLE calendar_sync_state FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON calendar_sync_state;
+CREATE POLICY tenant_isolation ON calendar_sync_state
+    USING (user_id IN (SELECT current_tenant_members()));
+
+ALTER TABLE external_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE external_events FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON external_events;
+CREATE POLICY tenant_isolation ON external_events
+    USING (user_id IN (SELECT current_tenant_members()));
+
+ALTER TABLE ics_feeds ENABLE ROW LEVEL SECURITY;
+ALTER TABLE ics_feeds FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON ics_feeds;
+CREATE POLICY tenant_isolation ON ics_feeds
+    USING (user_id IN (SELECT current_tenant_members()));
+
+ALTER TABLE ics_feed_busy ENABLE ROW LEVEL SECURITY;
+ALTER TABLE ics_feed_busy FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON ics_feed_busy;
+CREATE POLICY tenant_isolation ON ics_feed_busy
+    USING (user_id IN (SELECT current_tenant_members()));
+
+-- Left without row-level security:
+--   organizations, memberships and users hold identities, not scheduling
+--     data, and are read to resolve a caller's organization before one is set;
+--   api_keys is looked up by key hash to authenticate a request before its
+--     organization is known, and every management query filters tenant_id;
+--   oauth_states is only reached through the hash of an unguessable state,
+--     in the unauthenticated start and callback of an authorization.